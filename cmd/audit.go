@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/mudrex/onyx/pkg/audit"
+	configPkg "github.com/mudrex/onyx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditVerifyLocalOnly bool
+	auditSearchFilter    audit.Filter
+	auditSearchSince     string
+	auditSearchUntil     string
+	auditSearchLocalOnly bool
+	auditSearchJSON      bool
+)
+
+var auditCommand = &cobra.Command{
+	Use:   "audit",
+	Short: "Actions to be performed on the audit log",
+}
+
+var auditVerifyCommand = &cobra.Command{
+	Use:   "verify [--local]",
+	Short: "Verifies the hash chain of the audit log",
+	Long:  `Walks the hash chain across the archived logs on S3 and the local log, reporting every entry which was edited, removed or inserted.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx audit verify\nonyx audit verify --local",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return audit.Verify(ctx, cfg, auditVerifyLocalOnly)
+	},
+}
+
+var auditFlushCommand = &cobra.Command{
+	Use:   "flush",
+	Short: "Uploads rotated audit logs to the audit bucket",
+	Long:  `Uploads the local audit logs rotated after passing 1 MB to the audit bucket. Rotated logs are uploaded as soon as they rotate, this retries the uploads which failed then and can be run from cron.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx audit flush",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return audit.Flush(context.Background())
+	},
+}
+
+var auditSearchCommand = &cobra.Command{
+	Use:   "search [--user] [--action] [--host] [--service] [--since] [--until]",
+	Short: "Searches the archived and local audit logs",
+	Long:  `Streams the archived logs on S3 for the time range along with the local log and prints the matching entries in time order. --since and --until take either an RFC3339 time or a duration before now, like 24h.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx audit search --user john --since 24h\nonyx audit search --action ecs/spawn-shell --service api --since 2022-06-01T00:00:00Z --until 2022-06-02T00:00:00Z",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		filter := auditSearchFilter
+		if filter.Since, err = parseAuditTime(auditSearchSince); err != nil {
+			return err
+		}
+
+		if filter.Until, err = parseAuditTime(auditSearchUntil); err != nil {
+			return err
+		}
+
+		return audit.Search(ctx, cfg, filter, auditSearchLocalOnly, auditSearchJSON)
+	},
+}
+
+// parseAuditTime parses either an RFC3339 time or a duration before now
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expected RFC3339 time or a duration like 24h", value)
+	}
+
+	return parsed, nil
+}
+
+func init() {
+	auditCommand.AddCommand(auditVerifyCommand, auditFlushCommand, auditSearchCommand)
+
+	auditVerifyCommand.Flags().BoolVar(&auditVerifyLocalOnly, "local", false, "Verify only the local logs, skipping the archives on S3")
+
+	auditSearchCommand.Flags().StringVar(&auditSearchFilter.User, "user", "", "User who performed the action")
+	auditSearchCommand.Flags().StringVar(&auditSearchFilter.Action, "action", "", "Action prefix, like ssh/do, ecs/spawn-shell or ecs/tail-logs")
+	auditSearchCommand.Flags().StringVar(&auditSearchFilter.Host, "host", "", "Host the action was performed on")
+	auditSearchCommand.Flags().StringVar(&auditSearchFilter.Service, "service", "", "Service the action was performed on")
+	auditSearchCommand.Flags().StringVar(&auditSearchSince, "since", "", "Start of the time range")
+	auditSearchCommand.Flags().StringVar(&auditSearchUntil, "until", "", "End of the time range. Defaults to now")
+	auditSearchCommand.Flags().BoolVar(&auditSearchLocalOnly, "local", false, "Search only the local logs, skipping the archives on S3")
+	auditSearchCommand.Flags().BoolVar(&auditSearchJSON, "json", false, "Print matching entries as JSON")
+}
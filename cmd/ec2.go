@@ -7,6 +7,7 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	configPkg "github.com/mudrex/onyx/pkg/config"
@@ -21,6 +22,8 @@ var securityGroupEnv string
 var securityGroupID string
 var securityGroupFilter []string
 var securityGroupSkipChoice bool
+var securityGroupRuleTTL time.Duration
+var securityGroupReapDryRun bool
 
 var instanceID string
 var instanceTagName string
@@ -126,7 +129,7 @@ var ec2sgAuthorizeCommand = &cobra.Command{
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
 	},
-	Example: "onyx ec2 sg authorize production -t ssh\nonyx ec2 sg authorize staging -t ssh,mongo,redis\nonyx ec2 sg authorize sg-ajvjTUf581ig1 -t ssh,mongo,redis",
+	Example: "onyx ec2 sg authorize production -t ssh\nonyx ec2 sg authorize staging -t ssh,mongo,redis\nonyx ec2 sg authorize sg-ajvjTUf581ig1 -t ssh,mongo,redis\nonyx ec2 sg authorize staging -t ssh --ttl 2h",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var ports []int32
 		if securityGroupIngressPorts != "" {
@@ -144,7 +147,7 @@ var ec2sgAuthorizeCommand = &cobra.Command{
 			}
 		}
 
-		return ec2.AuthorizeOrRevokeRule(args[0], types, ports, securityGroupFilter, securityGroupSkipChoice, true)
+		return ec2.AuthorizeOrRevokeRule(args[0], types, ports, securityGroupFilter, securityGroupSkipChoice, true, securityGroupRuleTTL)
 	},
 }
 
@@ -174,7 +177,27 @@ var ec2sgRevokeCommand = &cobra.Command{
 			}
 		}
 
-		return ec2.AuthorizeOrRevokeRule(args[0], types, ports, securityGroupFilter, securityGroupSkipChoice, false)
+		return ec2.AuthorizeOrRevokeRule(args[0], types, ports, securityGroupFilter, securityGroupSkipChoice, false, 0)
+	},
+}
+
+var ec2sgReapCommand = &cobra.Command{
+	Use:   "reap [--env <environment>] [--dry-run]",
+	Short: "Revokes expired security group rules",
+	Long:  `Scans all security groups, filtered by environment if provided, and revokes the onyx approved rules whose expiry (set via --ttl on authorize) has passed.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ec2 sg reap\nonyx ec2 sg reap --env staging --dry-run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ec2.ReapExpiredRules(ctx, cfg, securityGroupEnv, securityGroupReapDryRun)
 	},
 }
 
@@ -231,7 +254,7 @@ func init() {
 
 	ec2InstanceCommand.AddCommand(ec2StopInstanceCommand, ec2StartInstanceCommand)
 
-	ec2SgCommand.AddCommand(ec2sgAuthorizeCommand, ec2sgRevokeCommand, ec2sgDescribeCommand, ec2sgListCommand)
+	ec2SgCommand.AddCommand(ec2sgAuthorizeCommand, ec2sgRevokeCommand, ec2sgDescribeCommand, ec2sgListCommand, ec2sgReapCommand)
 
 	ec2sgListCommand.Flags().StringVarP(&securityGroupEnv, "env", "e", "", "Environment for which to list. Allowed values production|staging")
 
@@ -242,12 +265,16 @@ func init() {
 	ec2sgAuthorizeCommand.Flags().StringVarP(&securityGroupIngressPorts, "ports", "p", "", "Ports to authorize. Allowed values 0-65536.  Accepted input: comma separated ports, example: 22, 1331.")
 	ec2sgAuthorizeCommand.Flags().StringSliceVarP(&securityGroupFilter, "filter", "f", []string{}, "Custom filters to filter out security groups from list. Example: name=entry or desc=load. Can be used mutiple times.")
 	ec2sgAuthorizeCommand.Flags().BoolVarP(&securityGroupSkipChoice, "skip-choice", "s", false, "If the choice list returns one choice, then this flag by bypasses the need to manually enter that choice and proceeds.")
+	ec2sgAuthorizeCommand.Flags().DurationVarP(&securityGroupRuleTTL, "ttl", "", 0, "Time after which the rule expires and is revoked by `onyx ec2 sg reap`, example: 2h. Rules never expire if not provided.")
 
 	ec2sgRevokeCommand.Flags().StringVarP(&securityGroupIngressTypes, "types", "t", "", "Types of rule to authorize. Allowed ssh|redis|mongo|mysql (required). Accepted input: comma separated types, example: ssh, mysql.")
 	ec2sgRevokeCommand.Flags().StringVarP(&securityGroupIngressPorts, "ports", "p", "", "Ports to authorize. Allowed values 0-65536.  Accepted input: comma separated ports, example: 22, 1331.")
 	ec2sgRevokeCommand.Flags().StringSliceVarP(&securityGroupFilter, "filter", "f", []string{}, "Custom filters to filter out security groups from list. Example: name=entry or desc=load. Can be used mutiple times.")
 	ec2sgRevokeCommand.Flags().BoolVarP(&securityGroupSkipChoice, "skip-choice", "s", false, "If the choice list returns one choice, then this flag by bypasses the need to manually enter that choice and proceeds.")
 
+	ec2sgReapCommand.Flags().StringVarP(&securityGroupEnv, "env", "e", "", "Environment for which to reap expired rules. Allowed values production|staging")
+	ec2sgReapCommand.Flags().BoolVarP(&securityGroupReapDryRun, "dry-run", "", false, "Only list the expired rules without revoking them")
+
 	ec2StartInstanceCommand.Flags().StringVarP(&instanceID, "id", "", "", "EC2 Instance ID")
 	ec2StartInstanceCommand.Flags().StringVarP(&instanceTagName, "name", "", "", "EC2 Instance tagged name")
 	ec2StopInstanceCommand.Flags().StringVarP(&instanceID, "id", "", "", "EC2 Instance ID")
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	configPkg "github.com/mudrex/onyx/pkg/config"
@@ -17,6 +20,25 @@ var tagToRevertTo string
 var revisionsToLookback int32
 var ecsContainerShell string
 var tailLogs int32
+var ecsContainerName string
+var ecsTaskID string
+var tailLogsSince time.Duration
+var tailLogsFilter string
+var tailLogsRaw bool
+var tailLogsCloudWatch bool
+var ecsDeployTag string
+var ecsDeployTimeout time.Duration
+var ecsSkipChoice bool
+var ecsTDDiffJSON bool
+var ecsDescribeEvents int
+var ecsDescribeJSON bool
+var ecsExecParallelism int
+var ecsScaleDryRun bool
+var ecsScaleParallelism int
+var ecsScaleSkipChoice bool
+var ecsAutoscalingActivities int
+var ecsAutoscalingDryRun bool
+var ecsPortForwardIdleTimeout time.Duration
 
 var ecsCommand = &cobra.Command{
 	Use:   "ecs",
@@ -24,14 +46,14 @@ var ecsCommand = &cobra.Command{
 }
 
 var ecsDescribeCommand = &cobra.Command{
-	Use:   "describe --cluster <cluster-name> [--service <service-name>]",
-	Short: "Describes the given ECS cluster tasks.",
-	Long:  `Lists down the private IP's of the ec2 instances the tasks of the cluster are running on, filtered by service name if provided.`,
+	Use:   "describe --cluster <cluster-name> [--service <service-name>] [--events n] [--json]",
+	Short: "Describes the given ECS cluster services and tasks.",
+	Long:  `Shows per service the desired, running and pending counts, the active deployments with their rollout state and the latest service events. Each running or recently stopped task is listed with its status, health, private IP, task definition revision and the image tag, exit code and reason of its containers, along with the stop reason.`,
 	Args:  cobra.NoArgs,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
 	},
-	Example: "onyx ecs describe --cluster staging-api-cluster \nonyx ecs describe --cluster staging-api-cluster --service some-service",
+	Example: "onyx ecs describe --cluster staging-api-cluster \nonyx ecs describe --cluster staging-api-cluster --service some-service --events 10 --json",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
 		if err != nil {
@@ -43,14 +65,14 @@ var ecsDescribeCommand = &cobra.Command{
 			return errors.New("empty cluster name")
 		}
 
-		return ecs.Describe(ctx, cfg, ecsServiceName, ecsClusterName)
+		return ecs.Describe(ctx, cfg, ecsServiceName, ecsClusterName, ecsDescribeEvents, ecsDescribeJSON)
 	},
 }
 
 var ecsSpawnShellCommand = &cobra.Command{
-	Use:   "spawn-shell --cluster <cluster-name> --service <service-name> [--shell=<shell-name>]",
+	Use:   "spawn-shell --cluster <cluster-name> --service <service-name> [--shell=<shell-name>] [--container <container-name>] [--task <task-id>]",
 	Short: "SpawnShells the given service.",
-	Long:  `For the given cluster and service name pair, onyx spawns the docker shell bypassing the host instance's shell`,
+	Long:  `For the given cluster and service name pair, onyx spawns the docker shell bypassing the host instance's shell. Clusters with shell_mode exec in ecs_clusters use ECS Exec instead, which also works on Fargate.`,
 	Args:  cobra.NoArgs,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
@@ -79,7 +101,7 @@ var ecsSpawnShellCommand = &cobra.Command{
 			return errors.New("invalid shell. Allowed bash or sh")
 		}
 
-		return ecs.SpawnServiceShell(ctx, cfg, ecsServiceName, ecsClusterName, ecsContainerShell)
+		return ecs.SpawnServiceShell(ctx, cfg, ecsServiceName, ecsClusterName, ecsContainerName, ecsTaskID, ecsContainerShell)
 	},
 }
 
@@ -99,14 +121,14 @@ var ecsListAccessCommand = &cobra.Command{
 }
 
 var ecsTailLogsCommand = &cobra.Command{
-	Use:   "tail-logs --cluster <cluster-name> --service <service-name> [--tail n]",
+	Use:   "tail-logs --cluster <cluster-name> --service <service-name> [--tail n] [--cloudwatch] [--since 10m] [--filter <pattern>]",
 	Short: "Tail logs for a service container",
-	Long:  `For the given cluster and service name pair, onyx tails the docker instance`,
+	Long:  `For the given cluster and service name pair, onyx tails the docker instance. With --cloudwatch, or on clusters with logs_mode cloudwatch in ecs_clusters, onyx follows the awslogs streams of every running task of the service instead, prefixed with the task ID.`,
 	Args:  cobra.NoArgs,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
 	},
-	Example: "onyx ecs tail-logs --cluster staging-api-cluster --service some-service --tail 100",
+	Example: "onyx ecs tail-logs --cluster staging-api-cluster --service some-service --tail 100\nonyx ecs tail-logs --cluster staging-api-cluster --service some-service --cloudwatch --since 1h --filter ERROR",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
 		if err != nil {
@@ -122,18 +144,19 @@ var ecsTailLogsCommand = &cobra.Command{
 			return errors.New("empty service name")
 		}
 
-		return ecs.TailContainerLogs(ctx, cfg, ecsServiceName, ecsClusterName, tailLogs)
+		return ecs.TailContainerLogs(ctx, cfg, ecsServiceName, ecsClusterName, ecsContainerName, tailLogs, tailLogsSince, tailLogsFilter, tailLogsRaw, tailLogsCloudWatch)
 	},
 }
 
 var ecsScaleCommand = &cobra.Command{
-	Use:   "scale <up|down>",
+	Use:   "scale <up|down|restore> [--dry-run] [--parallel n] [--yes]",
 	Short: "Scales up ECS services up or down",
-	Args:  cobra.MaximumNArgs(1),
+	Long:  `Sets the min, max and desired counts of the services of ecs_scale_up_config for scale up or down, a few services at a time, after saving their current counts to sandstorm_state_bucket or the home directory. A scale which has not been restored yet is only overwritten after confirming, or with --yes. Restore sets the services back to the counts saved before the last scale, undoing a partial run. Exits with a non-zero status if any service failed.`,
+	Args:  cobra.ExactArgs(1),
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
 	},
-	Example: "onyx ecs scale up",
+	Example: "onyx ecs scale up --dry-run\nonyx ecs scale up\nonyx ecs scale restore",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
 		if err != nil {
@@ -141,7 +164,52 @@ var ecsScaleCommand = &cobra.Command{
 		}
 		ctx := context.Background()
 
-		return ecs.Scale(ctx, cfg, args[0])
+		return ecs.Scale(ctx, cfg, args[0], ecsScaleDryRun, ecsScaleSkipChoice, ecsScaleParallelism)
+	},
+}
+
+var ecsAutoscalingCommand = &cobra.Command{
+	Use:   "autoscaling",
+	Short: "Shows or sets the autoscaling policies of ECS services",
+}
+
+var ecsAutoscalingShowCommand = &cobra.Command{
+	Use:   "show --cluster <cluster-name> --service <service-name> [--activities n]",
+	Short: "Shows the autoscaling policies and activities of a service",
+	Long:  `Prints the min and max counts of the service's scalable target, its target tracking and step scaling policies, and its latest scaling activities with their causes.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs autoscaling show --cluster production --service user\nonyx ecs autoscaling show --cluster production --service user --activities 20",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ecs.ShowAutoscaling(ctx, cfg, ecsClusterName, ecsServiceName, ecsAutoscalingActivities)
+	},
+}
+
+var ecsAutoscalingSetCommand = &cobra.Command{
+	Use:   "set [--cluster <cluster-name>] [--service <service-name>] [--dry-run]",
+	Short: "Applies the target tracking policies of ecs_autoscaling_config",
+	Long:  `Creates or updates the cpu, memory and alb_request_count target tracking policies declared in ecs_autoscaling_config, keyed by cluster and service like ecs_scale_up_config, on the scalable targets of the services. Policies which are not in the file are reported but left as they are.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs autoscaling set --dry-run\nonyx ecs autoscaling set --cluster production --service user",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ecs.SetAutoscaling(ctx, cfg, ecsClusterName, ecsServiceName, ecsAutoscalingDryRun)
 	},
 }
 
@@ -186,7 +254,7 @@ var ecsUpdateContainerInstanceCommand = &cobra.Command{
 var ecsRevertToCommand = &cobra.Command{
 	Use:   "revert",
 	Short: "",
-	Long:  `Reverts the service to the tag provided. It looks for the given tag in last n revisions of the task definition family and reverts to that state`,
+	Long:  `Reverts the service to the tag provided. It looks for the given tag in last n revisions of the task definition family and reverts to that state. The tag is matched exactly against the image of the chosen container.`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
 	},
@@ -198,12 +266,164 @@ var ecsRevertToCommand = &cobra.Command{
 		}
 		ctx := context.Background()
 
-		return ecs.Revert(ctx, cfg, ecsClusterName, ecsServiceName, tagToRevertTo, revisionsToLookback)
+		return ecs.Revert(ctx, cfg, ecsClusterName, ecsServiceName, ecsContainerName, tagToRevertTo, revisionsToLookback)
+	},
+}
+
+var ecsDeployCommand = &cobra.Command{
+	Use:   "deploy --cluster <cluster-name> --service <service-name> --tag <tag> [--container <container-name>]",
+	Short: "Deploys a new image tag to the service",
+	Long:  `Registers a new revision of the service's task definition with the image tag of the container replaced and updates the service. The rollout is watched until stable and reverted to the previous revision if it fails or times out.`,
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs deploy --cluster production --service user --tag v0.0.13\nonyx ecs deploy --cluster production --service user --container worker --tag v0.0.13 --timeout 20m",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ecs.Deploy(ctx, cfg, ecsClusterName, ecsServiceName, ecsContainerName, ecsDeployTag, ecsDeployTimeout, ecsSkipChoice)
+	},
+}
+
+var ecsTDDiffCommand = &cobra.Command{
+	Use:   "td-diff [<family>:<revision> <family>:<revision>] [--cluster <cluster-name> --service <service-name> --past n]",
+	Short: "Shows the changes between two task definition revisions",
+	Long:  `Prints the per container changes between two task definition revisions: image, environment variables, secrets, CPU/memory, port mappings and log configuration. With --service, the revision the service runs is compared against the revision n revisions before the latest of its family.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if ecsServiceName != "" {
+			if len(args) != 0 {
+				return errors.New("revisions can't be given with --service")
+			}
+
+			if ecsClusterName == "" {
+				return errors.New("--cluster is required with --service")
+			}
+
+			return nil
+		}
+
+		if len(args) != 2 {
+			return errors.New("requires two revisions, or --cluster and --service")
+		}
+
+		return nil
+	},
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs td-diff user:41 user:42\nonyx ecs td-diff --cluster production --service user --past 1 --json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		if ecsServiceName != "" {
+			return ecs.DiffServiceTaskDefinitions(ctx, cfg, ecsClusterName, ecsServiceName, revisionsToLookback, ecsTDDiffJSON)
+		}
+
+		return ecs.DiffTaskDefinitions(ctx, cfg, args[0], args[1], ecsTDDiffJSON)
+	},
+}
+
+var ecsExecCommand = &cobra.Command{
+	Use:   "exec --cluster <cluster-name> --service <service-name> [--container <container-name>] [--parallel n] -- <command>",
+	Short: "Runs a command in every running task of the service",
+	Long:  `Runs a non-interactive command in the container of every running task of the service, a few tasks at a time, and prints the output of each task followed by a summary of the exit codes. Clusters with shell_mode exec in ecs_clusters use ECS Exec, others docker exec through the task's host.`,
+	Args:  cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs exec --cluster production --service user -- cat /app/config.yml\nonyx ecs exec --cluster production --service user --parallel 10 -- 'ps aux | grep node'",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ecs.ExecServiceCommand(ctx, cfg, ecsClusterName, ecsServiceName, ecsContainerName, strings.Join(args, " "), ecsExecParallelism)
+	},
+}
+
+var ecsRunCommand = &cobra.Command{
+	Use:   "run --cluster <cluster-name> --service <service-name> [--container <container-name>] -- <command>",
+	Short: "Runs a one-off task of the service, like a migration",
+	Long:  `Launches a standalone task with the service's current task definition, network configuration and capacity provider, with the command of the container overridden, and streams its logs until it stops. onyx exits with the exit code of the container.`,
+	Args:  cobra.MinimumNArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs run --cluster production --service user -- python manage.py migrate\nonyx ecs run --cluster production --service user --container worker -- bundle exec rake backfill",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		exitCode, err := ecs.RunServiceTask(ctx, cfg, ecsClusterName, ecsServiceName, ecsContainerName, args)
+		if err != nil {
+			return err
+		}
+
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+
+		return nil
+	},
+}
+
+var ecsPortForwardCommand = &cobra.Command{
+	Use:   "port-forward --cluster <cluster-name> --service <service-name> [--container <container-name>] [--task <task-id>] <local-port>:<container-port>",
+	Short: "Forwards a local port to a container of the service",
+	Long:  `Opens a local listener and tunnels its connections over ssh through the task's host to the container port. The tunnel closes when no data flows through it for the idle timeout.`,
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs port-forward --cluster production --service user 8080:80\nonyx ecs port-forward --cluster production --service user --idle-timeout 30m 9000",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ecs.PortForward(ctx, cfg, ecsClusterName, ecsServiceName, ecsContainerName, ecsTaskID, args[0], ecsPortForwardIdleTimeout)
+	},
+}
+
+var ecsCopyCommand = &cobra.Command{
+	Use:   "cp --cluster <cluster-name> [--container <container-name>] [--task <task-id>] <service>:<path> <local-path> | <local-path> <service>:<path>",
+	Short: "Copies a file to or from a container of the service",
+	Long:  `Copies a file between the local machine and a container of the service through the container's host. Files larger than max_copy_size_mb of onyx config, 100 MB by default, are refused. The size and sha256 checksum of the file are recorded in the audit log.`,
+	Args:  cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx ecs cp --cluster production user:/tmp/heap.hprof .\nonyx ecs cp --cluster production --container app fixtures.json user:/app/fixtures.json",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return ecs.Copy(ctx, cfg, ecsClusterName, ecsContainerName, ecsTaskID, args[0], args[1])
 	},
 }
 
 func init() {
-	ecsCommand.AddCommand(ecsDescribeCommand, ecsRestartServiceCommand, ecsUpdateContainerInstanceCommand, ecsRevertToCommand, ecsSpawnShellCommand, ecsTailLogsCommand, ecsListAccessCommand, ecsScaleCommand)
+	ecsCommand.AddCommand(ecsDescribeCommand, ecsRestartServiceCommand, ecsUpdateContainerInstanceCommand, ecsRevertToCommand, ecsSpawnShellCommand, ecsTailLogsCommand, ecsListAccessCommand, ecsScaleCommand, ecsDeployCommand, ecsTDDiffCommand, ecsExecCommand, ecsRunCommand, ecsPortForwardCommand, ecsCopyCommand, ecsAutoscalingCommand)
+	ecsAutoscalingCommand.AddCommand(ecsAutoscalingShowCommand, ecsAutoscalingSetCommand)
 
 	ecsRestartServiceCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
 	ecsRestartServiceCommand.MarkFlagRequired("cluster")
@@ -211,26 +431,91 @@ func init() {
 
 	ecsDescribeCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
 	ecsDescribeCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Filters tasks belonging to the service name provided. Returns the best matching service tasks. (required)")
+	ecsDescribeCommand.Flags().IntVarP(&ecsDescribeEvents, "events", "", 5, "Number of latest service events to show")
+	ecsDescribeCommand.Flags().BoolVar(&ecsDescribeJSON, "json", false, "Print the description as JSON")
 	ecsDescribeCommand.MarkFlagRequired("service")
 	ecsDescribeCommand.MarkFlagRequired("cluster")
 
 	ecsSpawnShellCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
 	ecsSpawnShellCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Filters tasks belonging to the service name provided. Returns the best matching service tasks. (required)")
 	ecsSpawnShellCommand.Flags().StringVarP(&ecsContainerShell, "shell", "", "", "Shell to use (bash or sh)")
+	ecsSpawnShellCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container to exec into. Defaults to the container named after the service (exec mode only)")
+	ecsSpawnShellCommand.Flags().StringVarP(&ecsTaskID, "task", "", "", "Task ID or ARN to exec into (exec mode only)")
 	ecsSpawnShellCommand.MarkFlagRequired("service")
 	ecsSpawnShellCommand.MarkFlagRequired("cluster")
 
 	ecsTailLogsCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
 	ecsTailLogsCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Filters tasks belonging to the service name provided. Returns the best matching service tasks. (required)")
 	ecsTailLogsCommand.Flags().Int32VarP(&tailLogs, "tail", "t", 10, "")
+	ecsTailLogsCommand.Flags().BoolVar(&tailLogsCloudWatch, "cloudwatch", false, "Follow the CloudWatch log streams of every running task")
+	ecsTailLogsCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container to follow. Defaults to every container shipping to CloudWatch (cloudwatch mode only)")
+	ecsTailLogsCommand.Flags().DurationVar(&tailLogsSince, "since", 10*time.Minute, "Show logs since the duration before now (cloudwatch mode only)")
+	ecsTailLogsCommand.Flags().StringVar(&tailLogsFilter, "filter", "", "CloudWatch Logs filter pattern (cloudwatch mode only)")
+	ecsTailLogsCommand.Flags().BoolVar(&tailLogsRaw, "raw", false, "Print JSON log lines as is instead of pretty printing them (cloudwatch mode only)")
 	ecsTailLogsCommand.MarkFlagRequired("service")
 	ecsTailLogsCommand.MarkFlagRequired("cluster")
 
 	ecsRevertToCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
 	ecsRevertToCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Filters tasks belonging to the service name provided. Returns the best matching service tasks.")
 	ecsRevertToCommand.Flags().StringVarP(&tagToRevertTo, "tag", "", "", "Tag to which the service will be reverted")
+	ecsRevertToCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container whose image tag is matched. Defaults to the container named after the service")
 	ecsRevertToCommand.MarkFlagRequired("cluster")
 	ecsRevertToCommand.MarkFlagRequired("service")
 	ecsRevertToCommand.MarkFlagRequired("tag")
 	ecsRevertToCommand.Flags().Int32VarP(&revisionsToLookback, "past", "", 5, "Revisions to look back the tag in. Max lookback is 50")
+
+	ecsScaleCommand.Flags().BoolVar(&ecsScaleDryRun, "dry-run", false, "Only print the current and target counts")
+	ecsScaleCommand.Flags().IntVarP(&ecsScaleParallelism, "parallel", "p", 5, "Number of services to scale at a time")
+	ecsScaleCommand.Flags().BoolVar(&ecsScaleSkipChoice, "yes", false, "Overwrite a snapshot which has not been restored without asking")
+
+	ecsDeployCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
+	ecsDeployCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Service Name (required)")
+	ecsDeployCommand.Flags().StringVarP(&ecsDeployTag, "tag", "", "", "Image tag to deploy (required)")
+	ecsDeployCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container to deploy the tag to. Defaults to the container named after the service")
+	ecsDeployCommand.Flags().DurationVar(&ecsDeployTimeout, "timeout", 15*time.Minute, "Time to wait for the deployment to be stable before reverting")
+	ecsDeployCommand.Flags().BoolVarP(&ecsSkipChoice, "skip-choice", "", false, "Deploy without asking for confirmation")
+	ecsDeployCommand.MarkFlagRequired("cluster")
+	ecsDeployCommand.MarkFlagRequired("service")
+	ecsDeployCommand.MarkFlagRequired("tag")
+
+	ecsExecCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
+	ecsExecCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Service Name (required)")
+	ecsExecCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container to run the command in. Defaults to the container named after the service")
+	ecsExecCommand.Flags().IntVarP(&ecsExecParallelism, "parallel", "p", 5, "Number of tasks to run the command in at a time")
+	ecsExecCommand.MarkFlagRequired("cluster")
+	ecsExecCommand.MarkFlagRequired("service")
+
+	ecsRunCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
+	ecsRunCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Service Name (required)")
+	ecsRunCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container whose command is overridden. Defaults to the container named after the service")
+	ecsRunCommand.MarkFlagRequired("cluster")
+	ecsRunCommand.MarkFlagRequired("service")
+
+	ecsPortForwardCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
+	ecsPortForwardCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Service Name (required)")
+	ecsPortForwardCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container to forward to. Defaults to the container named after the service")
+	ecsPortForwardCommand.Flags().StringVarP(&ecsTaskID, "task", "", "", "Task to forward to. Prompts if the service runs several tasks")
+	ecsPortForwardCommand.Flags().DurationVar(&ecsPortForwardIdleTimeout, "idle-timeout", 15*time.Minute, "Close the tunnel after no traffic for this long")
+	ecsPortForwardCommand.MarkFlagRequired("cluster")
+	ecsPortForwardCommand.MarkFlagRequired("service")
+
+	ecsCopyCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
+	ecsCopyCommand.Flags().StringVarP(&ecsContainerName, "container", "", "", "Container to copy to or from. Defaults to the container named after the service")
+	ecsCopyCommand.Flags().StringVarP(&ecsTaskID, "task", "", "", "Task to copy to or from. Prompts if the service runs several tasks")
+	ecsCopyCommand.MarkFlagRequired("cluster")
+
+	ecsTDDiffCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name, required with --service")
+	ecsTDDiffCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Compare the revision the service runs instead of the given revisions")
+	ecsTDDiffCommand.Flags().Int32VarP(&revisionsToLookback, "past", "", 1, "Revisions before the latest to compare the running revision against. Max lookback is 50")
+	ecsTDDiffCommand.Flags().BoolVar(&ecsTDDiffJSON, "json", false, "Print the diff as JSON")
+
+	ecsAutoscalingShowCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Cluster Name (required)")
+	ecsAutoscalingShowCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Service Name (required)")
+	ecsAutoscalingShowCommand.Flags().IntVarP(&ecsAutoscalingActivities, "activities", "", 10, "Number of latest scaling activities to show, at most 50")
+	ecsAutoscalingShowCommand.MarkFlagRequired("cluster")
+	ecsAutoscalingShowCommand.MarkFlagRequired("service")
+
+	ecsAutoscalingSetCommand.Flags().StringVarP(&ecsClusterName, "cluster", "c", "", "Only apply the policies of this cluster")
+	ecsAutoscalingSetCommand.Flags().StringVarP(&ecsServiceName, "service", "s", "", "Only apply the policies of this service")
+	ecsAutoscalingSetCommand.Flags().BoolVar(&ecsAutoscalingDryRun, "dry-run", false, "Only print the planned changes")
 }
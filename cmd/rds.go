@@ -11,6 +11,12 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	rdsPlanFile    string
+	rdsDriftNotify bool
+	rdsDatabase    string
+)
+
 var rdsCommand = &cobra.Command{
 	Use:   "rds",
 	Short: "Actions to be performed on RDS",
@@ -41,6 +47,94 @@ var rdsRefreshAccessCommand = &cobra.Command{
 	},
 }
 
+var rdsPlanCommand = &cobra.Command{
+	Use:   "plan <users|services> [--out <planfile>]",
+	Short: "Shows the changes refresh-access would make and saves them to a plan file",
+	Long:  `Prints the exact CREATE USER, DROP USER, GRANT and REVOKE statements grouped by user, highlighting critical tables. The plan can then be executed with apply.`,
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx rds plan users\nonyx rds plan services --out services.plan",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		planFile := rdsPlanFile
+		if planFile == "" {
+			planFile = "rds-" + args[0] + ".plan"
+		}
+
+		return rds.CreatePlan(ctx, cfg, args[0], planFile)
+	},
+}
+
+var rdsApplyCommand = &cobra.Command{
+	Use:   "apply <planfile>",
+	Short: "Applies a plan created by plan",
+	Long:  `Executes the statements of the plan file only if the access config and its lock are unchanged since the plan was created.`,
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx rds apply rds-users.plan",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return rds.ApplyPlan(ctx, cfg, args[0])
+	},
+}
+
+var rdsDriftCommand = &cobra.Command{
+	Use:   "drift <users|services> [--notify]",
+	Short: "Compares the live grants of every user in the access config with the lock file",
+	Long:  `Reads the privileges from the grant tables of the database and lists the extra and missing grants per user, table and column. Exits with an error if any drift is found.`,
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx rds drift users\nonyx rds drift services --notify",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return rds.DetectDrift(ctx, cfg, args[0], rdsDriftNotify)
+	},
+}
+
+var rdsRotatePasswordCommand = &cobra.Command{
+	Use:   "rotate-password <user> [--database <alias>]",
+	Short: "Resets the password of a database user and updates its secret",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx rds rotate-password john\nonyx rds rotate-password john --database analytics",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return rds.RotatePassword(ctx, cfg, rdsDatabase, args[0])
+	},
+}
+
 func init() {
-	rdsCommand.AddCommand(rdsRefreshAccessCommand)
+	rdsCommand.AddCommand(rdsRefreshAccessCommand, rdsPlanCommand, rdsApplyCommand, rdsDriftCommand, rdsRotatePasswordCommand)
+
+	rdsPlanCommand.Flags().StringVarP(&rdsPlanFile, "out", "o", "", "File to save the plan to. Defaults to rds-<type>.plan")
+	rdsDriftCommand.Flags().BoolVar(&rdsDriftNotify, "notify", false, "Notify on slack if grants on critical tables have drifted")
+	rdsRotatePasswordCommand.Flags().StringVarP(&rdsDatabase, "database", "d", "default", "Database alias from rds_secrets. Defaults to the rds_secret_name database")
 }
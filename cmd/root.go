@@ -27,6 +27,8 @@ func init() {
 		rdsCommand,
 		optimusCommand,
 		pkiCommand,
+		auditCommand,
+		scheduleCommand,
 	)
 }
 
@@ -11,31 +11,32 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var sandstormDryRun bool
+
 var sandstormCommand = &cobra.Command{
-	Use:     "sandstorm <env> <init|revert>",
+	Use:     "sandstorm <env> <init|revert> [--dry-run]",
 	Short:   "Starts or stops entire ecs infra",
-	Args:    cobra.MinimumNArgs(1),
-	Example: "onyx sandstorm init\nonyx sandstorm revert",
+	Long:    `Stops the services of the environment listed in sandstorm_config on init, after saving a snapshot of their desired, min and max counts and autoscaling suspension state to sandstorm_state_bucket or the home directory. The ec2 instances, rds instances and aurora clusters tagged Environment=<env>, with env exactly as given, are stopped once the services have no running tasks. Revert starts the databases, waits for them to be available, starts the instances and then restores the services to exactly that snapshot.`,
+	Args:    cobra.ExactArgs(2),
+	Example: "onyx sandstorm staging init --dry-run\nonyx sandstorm staging init\nonyx sandstorm staging revert",
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		return configPkg.LoadConfig()
 	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return errors.New("Disabled")
 		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
 		if err != nil {
 			log.Fatalf("unable to load SDK config, %v", err)
 		}
 		ctx := context.Background()
-		if args[0] != "staging" && args[0] != "production" {
-			return errors.New("Invalid env: " + args[0])
-		}
 
 		if args[1] != "init" && args[1] != "revert" {
 			return errors.New("Invalid type: " + args[1])
 		}
 
-		sandstorm.Process(ctx, cfg, args[0], args[1])
-
-		return nil
+		return sandstorm.Process(ctx, cfg, args[0], args[1], sandstormDryRun)
 	},
 }
+
+func init() {
+	sandstormCommand.Flags().BoolVarP(&sandstormDryRun, "dry-run", "", false, "Only print the planned changes")
+}
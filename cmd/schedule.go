@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	configPkg "github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/core/schedule"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleEnv  string
+	scheduleCron string
+)
+
+var scheduleCommand = &cobra.Command{
+	Use:   "schedule",
+	Short: "Schedules scale and sandstorm of ECS services",
+	Long:  `Manages EventBridge rules which run onyx ecs scale and onyx sandstorm on schedule_instance_id through SSM Run Command, as schedule_user if it is set, so that they run on schedule without a laptop. EventBridge assumes schedule_role_arn to send the command.`,
+}
+
+var scheduleAddCommand = &cobra.Command{
+	Use:   "add <scale-up|scale-down|sandstorm-init|sandstorm-revert> --cron <expression> [--env <env>]",
+	Short: "Adds or updates a schedule",
+	Long:  `Puts a rule which runs onyx ecs scale up --yes or down --yes for scale-up and scale-down, or onyx sandstorm <env> init or revert for sandstorm-init and sandstorm-revert, exactly as they run by hand. Schedules are in UTC. A scheduled scale overwrites the snapshot of a scale which was not restored.`,
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx schedule add scale-up --cron \"45 21 ? * SUN-THU *\"\nonyx schedule add sandstorm-init --env staging --cron \"0 20 * * ? *\"",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return schedule.Add(ctx, cfg, args[0], scheduleEnv, scheduleCron)
+	},
+}
+
+var scheduleListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the schedules",
+	Args:  cobra.NoArgs,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx schedule list",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return schedule.List(ctx, cfg)
+	},
+}
+
+var scheduleRemoveCommand = &cobra.Command{
+	Use:   "remove <scale-up|scale-down|sandstorm-init|sandstorm-revert> [--env <env>]",
+	Short: "Removes a schedule",
+	Args:  cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	Example: "onyx schedule remove scale-up\nonyx schedule remove sandstorm-init --env staging",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(configPkg.GetRegion()))
+		if err != nil {
+			log.Fatalf("unable to load SDK config, %v", err)
+		}
+		ctx := context.Background()
+
+		return schedule.Remove(ctx, cfg, args[0], scheduleEnv)
+	},
+}
+
+func init() {
+	scheduleCommand.AddCommand(scheduleAddCommand, scheduleListCommand, scheduleRemoveCommand)
+
+	scheduleAddCommand.Flags().StringVar(&scheduleCron, "cron", "", "Cron expression in UTC with 6 fields, like \"0 3 ? * MON-FRI *\", or a rate() expression")
+	scheduleAddCommand.Flags().StringVar(&scheduleEnv, "env", "", "Environment of sandstorm_config, required for sandstorm schedules")
+	scheduleAddCommand.MarkFlagRequired("cron")
+
+	scheduleRemoveCommand.Flags().StringVar(&scheduleEnv, "env", "", "Environment of sandstorm_config, required for sandstorm schedules")
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"time"
 
 	configPkg "github.com/mudrex/onyx/pkg/config"
 	"github.com/mudrex/onyx/pkg/core/ssh"
@@ -27,6 +28,44 @@ var sshDoCommand = &cobra.Command{
 	},
 }
 
+var sshTunnelVia string
+var sshTunnelLocalPort int
+var sshTunnelIdleTimeout time.Duration
+
+var sshTunnelCommand = &cobra.Command{
+	Use:     "tunnel <host>:<port> [--via <user>@<ip>] [--local-port <port>]",
+	Short:   "Forwards a local port to a private endpoint through an instance",
+	Long:    "Opens a local listener and tunnels its connections over ssh through the instance given by --via, or tunnel_host of onyx config, to the endpoint, like an RDS or Redis endpoint in the VPC. The tunnel closes when no data flows through it for the idle timeout.",
+	Example: "onyx ssh tunnel db.internal:5432 --via ec2-user@10.10.1.12\nonyx ssh tunnel redis.internal:6379 --local-port 16379 --idle-timeout 30m",
+	Args:    cobra.ExactArgs(1),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		return ssh.Tunnel(ctx, args[0], sshTunnelVia, sshTunnelLocalPort, sshTunnelIdleTimeout)
+	},
+}
+
+var sshCopyCommand = &cobra.Command{
+	Use:     "cp <user>@<ip>:<path> <local-path> | <local-path> <user>@<ip>:<path>",
+	Short:   "Copies a file to or from a remote machine",
+	Long:    "Copies a file over ssh between the local machine and the remote machine. Files larger than max_copy_size_mb of onyx config, 100 MB by default, are refused. The size and sha256 checksum of the file are recorded in the audit log.",
+	Example: "onyx ssh cp ec2-user@10.10.1.12:/var/log/messages .\nonyx ssh cp fixtures.json ec2-user@10.10.1.12:/tmp/fixtures.json",
+	Args:    cobra.ExactArgs(2),
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		return configPkg.LoadConfig()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := context.Background()
+		return ssh.Copy(ctx, args[0], args[1])
+	},
+}
+
 func init() {
-	sshCommand.AddCommand(sshDoCommand)
+	sshCommand.AddCommand(sshDoCommand, sshTunnelCommand, sshCopyCommand)
+
+	sshTunnelCommand.Flags().StringVarP(&sshTunnelVia, "via", "", "", "Instance to tunnel through as <user>@<ip>. Defaults to tunnel_host of onyx config")
+	sshTunnelCommand.Flags().IntVarP(&sshTunnelLocalPort, "local-port", "", 0, "Local port to listen on. Defaults to the port of the endpoint")
+	sshTunnelCommand.Flags().DurationVar(&sshTunnelIdleTimeout, "idle-timeout", 15*time.Minute, "Close the tunnel after no traffic for this long")
 }
@@ -3,19 +3,23 @@ module github.com/mudrex/onyx
 go 1.16
 
 require (
-	github.com/aws/aws-sdk-go-v2 v1.16.4
+	github.com/aws/aws-sdk-go-v2 v1.16.7
 	github.com/aws/aws-sdk-go-v2/config v1.15.7
 	github.com/aws/aws-sdk-go-v2/feature/s3/manager v1.11.12
 	github.com/aws/aws-sdk-go-v2/service/applicationautoscaling v1.2.3
 	github.com/aws/aws-sdk-go-v2/service/cloudwatchevents v1.3.2
+	github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs v1.15.8
 	github.com/aws/aws-sdk-go-v2/service/ec2 v1.5.0
 	github.com/aws/aws-sdk-go-v2/service/ecr v1.14.0
 	github.com/aws/aws-sdk-go-v2/service/ecs v1.2.2
 	github.com/aws/aws-sdk-go-v2/service/iam v1.3.1
+	github.com/aws/aws-sdk-go-v2/service/rds v1.22.0
 	github.com/aws/aws-sdk-go-v2/service/s3 v1.26.10
 	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.13.0
 	github.com/aws/aws-sdk-go-v2/service/wafv2 v1.14.1
 	github.com/fatih/color v1.10.0
+	github.com/go-sql-driver/mysql v1.6.0
+	github.com/lib/pq v1.10.6
 	github.com/spf13/cobra v1.1.3
 	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 	golang.org/x/sys v0.0.0-20220412211240-33da011f77ad // indirect
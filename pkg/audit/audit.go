@@ -2,10 +2,19 @@ package audit
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,41 +23,270 @@ import (
 	s3Lib "github.com/aws/aws-sdk-go-v2/service/s3"
 	configPkg "github.com/mudrex/onyx/pkg/config"
 	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/utils"
 )
 
-func Log(ctx context.Context, message string) {
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeDenied  = "denied"
+)
+
+// maxLogSize is the size after which the local log is rotated and uploaded
+const maxLogSize = 1000000
+
+// rotatedTimeFormat is appended to the name of rotated log files and later used for their S3 key
+const rotatedTimeFormat = "20060102T150405"
+
+// Entry is a single audit record. Hash covers every other field including PrevHash,
+// chaining each entry to the one before it.
+type Entry struct {
+	Time     string `json:"time"`
+	Actor    string `json:"actor"`
+	Command  string `json:"command"`
+	Target   string `json:"target"`
+	Outcome  string `json:"outcome"`
+	Message  string `json:"message"`
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+func (e Entry) computeHash() string {
+	e.Hash = ""
+	entryBytes, _ := json.Marshal(e)
+
+	hash := sha256.Sum256(entryBytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// Log appends the entry to the local log, linking it to the previous entry. Once the log passes
+// 1 MB it is rotated aside and uploaded to the audit bucket, rotated logs which could not be uploaded
+// are left for the next rotation or onyx audit flush.
+func Log(ctx context.Context, entry Entry) {
+	if !appendEntry(entry) {
+		return
+	}
+
+	if err := Flush(ctx); err != nil {
+		logger.Warn("Unable to upload the rotated audit logs, run onyx audit flush to retry. Error: %s", err.Error())
+	}
+}
+
+// appendEntry writes the entry to the local log and returns whether the log was rotated
+func appendEntry(entry Entry) bool {
+	// the head file holds the hash of the last entry
+	lock, err := lockLog()
+	if err != nil {
+		logger.Error("Unable to lock log file. Error: %s", err.Error())
+		return false
+	}
+
+	defer lock.Close()
+
+	prevHash, err := os.ReadFile(headFilename())
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error("Unable to read log head. Error: %s", err.Error())
+		return false
+	}
+
+	if entry.Time == "" {
+		entry.Time = time.Now().Format(time.RFC3339)
+	}
+
+	if entry.Actor == "" {
+		entry.Actor = utils.GetUser()
+	}
+
+	entry.PrevHash = strings.TrimSpace(string(prevHash))
+	entry.Hash = entry.computeHash()
+
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		logger.Error("Unable to encode log entry. Error: %s", err.Error())
+		return false
+	}
+
 	f, err := os.OpenFile(configPkg.Config.LocalLogFilename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
 		logger.Error("Unable to open file. Error: %s", err.Error())
-		return
+		return false
 	}
 
 	defer f.Close()
 
+	if _, err = f.Write(append(entryBytes, '\n')); err != nil {
+		logger.Error("Unable to write to log file. Error: %s", err.Error())
+		return false
+	}
+
+	if err := writeHead(entry.Hash); err != nil {
+		logger.Error("Unable to write log head. Error: %s", err.Error())
+	}
+
 	fi, err := f.Stat()
 	if err != nil {
 		logger.Error("Unable to get file size. Error: %s", err.Error())
-		return
+		return false
+	}
+
+	if fi.Size() <= maxLogSize {
+		return false
+	}
+
+	rotatedFilename := configPkg.Config.LocalLogFilename + "." + time.Now().Format(rotatedTimeFormat)
+	if err := os.Rename(configPkg.Config.LocalLogFilename, rotatedFilename); err != nil {
+		logger.Error("Unable to rotate log file. Error: %s", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// writeHead replaces the head file through a rename so that a crash never leaves it half written
+func writeHead(hash string) error {
+	tmpFilename := headFilename() + ".tmp"
+
+	f, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(hash); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilename, headFilename())
+}
+
+// lockLog takes the lock which serializes the writers of the local log and the uploads of the rotated logs,
+// closing the returned file releases it
+func lockLog() (*os.File, error) {
+	lock, err := os.OpenFile(lockFilename(), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
 	}
 
-	if fi.Size() > 1000000 { // greater than 1mb
-		fmt.Println("Flushing log file. Might take some time for the next action.")
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+func lockFilename() string {
+	return configPkg.Config.LocalLogFilename + ".lock"
+}
+
+func headFilename() string {
+	return configPkg.Config.LocalLogFilename + ".head"
+}
 
-		file, _ := os.Open(configPkg.Config.LocalLogFilename)
-		done := uploadToS3(ctx, file)
+// getRotatedFilenames returns the rotated log files pending upload, oldest first
+func getRotatedFilenames() ([]string, error) {
+	filenames, err := filepath.Glob(configPkg.Config.LocalLogFilename + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := make([]string, 0)
+	for _, filename := range filenames {
+		suffix := strings.TrimPrefix(filename, configPkg.Config.LocalLogFilename+".")
+		if _, err := time.Parse(rotatedTimeFormat, suffix); err == nil {
+			rotated = append(rotated, filename)
+		}
+	}
+
+	sort.Strings(rotated)
+
+	return rotated, nil
+}
+
+// Flush uploads the rotated log files to the audit bucket and removes the uploaded ones. Log runs it
+// on every rotation, running it from cron retries the uploads which failed then.
+func Flush(ctx context.Context) error {
+	// a flush from cron racing the one on rotation would upload the same file twice
+	lock, err := lockLog()
+	if err != nil {
+		return err
+	}
+
+	defer lock.Close()
+
+	rotatedFilenames, err := getRotatedFilenames()
+	if err != nil {
+		return err
+	}
+
+	if len(rotatedFilenames) == 0 {
+		logger.Info("Nothing to flush")
+		return nil
+	}
+
+	failed := 0
+	for _, filename := range rotatedFilenames {
+		rotatedAt, _ := time.Parse(rotatedTimeFormat, strings.TrimPrefix(filename, configPkg.Config.LocalLogFilename+"."))
+
+		file, err := os.Open(filename)
+		if err != nil {
+			logger.Error("Unable to open %s. Error: %s", filename, err.Error())
+			failed++
+			continue
+		}
+
+		done := uploadToS3(ctx, file, rotatedAt)
 		file.Close()
-		if done {
-			f.Truncate(0)
+		if !done {
+			failed++
+			continue
 		}
+
+		os.Remove(filename)
+		logger.Success("Flushed %s", logger.Underline(filename))
 	}
 
-	if _, err = f.WriteString(fmt.Sprintf("%s %s\n", time.Now().Format(time.RFC3339), message)); err != nil {
-		logger.Error("Unable to write to log file. Error: %s", err.Error())
-		return
+	if failed > 0 {
+		return fmt.Errorf("unable to flush %d log file(s)", failed)
+	}
+
+	return nil
+}
+
+func getArchivePrefix() string {
+	return fmt.Sprintf("onyx/logs/%s/", configPkg.Config.Environment)
+}
+
+// getChainID returns the id of the hash chain of this host, every host running onyx keeps its own
+func getChainID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return "unknown"
 	}
+
+	return hostname
+}
+
+// getArchiveChainID returns the chain id of an archive key, empty for archives uploaded before keys had one
+func getArchiveChainID(key string) string {
+	parts := strings.Split(strings.TrimPrefix(path.Base(key), "archive_log-"), "-")
+	if len(parts) < 3 {
+		return ""
+	}
+
+	return strings.Join(parts[:len(parts)-2], "-")
 }
 
-func uploadToS3(ctx context.Context, body io.Reader) bool {
+func uploadToS3(ctx context.Context, body io.Reader, rotatedAt time.Time) bool {
 	if len(configPkg.Config.AuditBucket) == 0 {
 		logger.Error("Unable to flush logs to s3. Please contact platform team.")
 		return false
@@ -59,24 +297,36 @@ func uploadToS3(ctx context.Context, body io.Reader) bool {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
 
-	s3Handler := s3Lib.NewFromConfig(cfg)
+	// logs rotated in the same second on different hosts would otherwise share a key
+	suffixBytes := make([]byte, 4)
+	if _, err := rand.Read(suffixBytes); err != nil {
+		logger.Error("Unable to generate archive key. Error: %s", err.Error())
+		return false
+	}
 
-	now := time.Now()
+	suffix := hex.EncodeToString(suffixBytes)
+
+	s3Handler := s3Lib.NewFromConfig(cfg)
 
 	uploader := manager.NewUploader(s3Handler)
 	_, err = uploader.Upload(ctx, &s3Lib.PutObjectInput{
 		Bucket: aws.String(configPkg.Config.AuditBucket),
 		Key: aws.String(
 			fmt.Sprintf(
-				"onyx/logs/%s/dt=%s/hour=%s/archive_log-%s",
-				configPkg.Config.Environment,
-				now.Format("20060102"),
-				now.Format("15"),
-				now.Format("04:05"),
+				"%sdt=%s/hour=%s/archive_log-%s-%s-%s",
+				getArchivePrefix(),
+				rotatedAt.Format("20060102"),
+				rotatedAt.Format("15"),
+				getChainID(),
+				rotatedAt.Format(rotatedTimeFormat),
+				suffix,
 			),
 		),
 		Body: body,
 	})
+	if err != nil {
+		logger.Error("Unable to upload logs to s3. Error: %s", err.Error())
+	}
 
 	return err == nil
 }
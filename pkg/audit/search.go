@@ -0,0 +1,179 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mudrex/onyx/pkg/logger"
+)
+
+// Filter selects the entries returned by search. Empty fields match everything.
+type Filter struct {
+	User    string
+	Action  string
+	Host    string
+	Service string
+	Since   time.Time
+	Until   time.Time
+}
+
+// legacyLine matches the `RFC3339 message` lines written before entries were structured,
+// where messages look like `[ecs/spawn-shell] *user* logged in to _host_ for service`
+var (
+	legacyLine    = regexp.MustCompile(`^(\S+) (.*)$`)
+	legacyCommand = regexp.MustCompile(`\[([^\]]+)\]`)
+	legacyActor   = regexp.MustCompile(`\*([^*]+)\*`)
+	legacyTarget  = regexp.MustCompile(`_([^_\s]+)_`)
+)
+
+func parseLegacyEntry(line string) (Entry, bool) {
+	matches := legacyLine.FindStringSubmatch(line)
+	if matches == nil {
+		return Entry{}, false
+	}
+
+	if _, err := time.Parse(time.RFC3339, matches[1]); err != nil {
+		return Entry{}, false
+	}
+
+	entry := Entry{Time: matches[1], Message: matches[2]}
+	if command := legacyCommand.FindStringSubmatch(entry.Message); command != nil {
+		entry.Command = command[1]
+	}
+
+	if actor := legacyActor.FindStringSubmatch(entry.Message); actor != nil {
+		entry.Actor = actor[1]
+	}
+
+	if target := legacyTarget.FindStringSubmatch(entry.Message); target != nil {
+		entry.Target = target[1]
+	}
+
+	return entry, true
+}
+
+func (f *Filter) matches(entry Entry, entryTime time.Time) bool {
+	if f.User != "" && entry.Actor != f.User {
+		return false
+	}
+
+	if f.Action != "" && !strings.HasPrefix(entry.Command, f.Action) {
+		return false
+	}
+
+	if f.Host != "" && !strings.Contains(entry.Target, f.Host) && !strings.Contains(entry.Message, f.Host) {
+		return false
+	}
+
+	if f.Service != "" && !strings.Contains(entry.Target, f.Service) && !strings.Contains(entry.Message, f.Service) {
+		return false
+	}
+
+	if !f.Since.IsZero() && entryTime.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && entryTime.After(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// getPartitionPrefixes returns the dt= partitions which can hold entries of the time range of the filter.
+// Logs are partitioned by the time they were rotated, which is after their entries were written,
+// so every partition from the start of the range until now is listed.
+func (f *Filter) getPartitionPrefixes() []string {
+	if f.Since.IsZero() {
+		return []string{getArchivePrefix()}
+	}
+
+	now := time.Now()
+	since := f.Since.In(now.Location())
+
+	prefixes := make([]string, 0)
+	for day := time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, now.Location()); !day.After(now); day = day.AddDate(0, 0, 1) {
+		prefixes = append(prefixes, fmt.Sprintf("%sdt=%s/", getArchivePrefix(), day.Format("20060102")))
+	}
+
+	return prefixes
+}
+
+// Search prints the entries of the archived and local logs matching the filter in time order
+func Search(ctx context.Context, cfg aws.Config, filter Filter, localOnly, asJSON bool) error {
+	type result struct {
+		entry Entry
+		time  time.Time
+	}
+
+	results := make([]result, 0)
+
+	err := readLogs(ctx, cfg, filter.getPartitionPrefixes(), localOnly, func(source string, lineNumber int, line string) {
+		entry, ok := parseEntry(line)
+		if !ok {
+			entry, ok = parseLegacyEntry(line)
+		}
+
+		if !ok {
+			return
+		}
+
+		entryTime, err := time.Parse(time.RFC3339, entry.Time)
+		if err != nil {
+			return
+		}
+
+		if filter.matches(entry, entryTime) {
+			results = append(results, result{entry: entry, time: entryTime})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].time.Before(results[j].time)
+	})
+
+	if asJSON {
+		entries := make([]Entry, 0)
+		for _, r := range results {
+			entries = append(entries, r.entry)
+		}
+
+		entriesBytes, err := json.MarshalIndent(entries, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(entriesBytes))
+		return nil
+	}
+
+	if len(results) == 0 {
+		logger.Info("No matching entries")
+		return nil
+	}
+
+	for _, r := range results {
+		fmt.Printf(
+			"%s  %-12s %-18s %-30s %-8s %s\n",
+			r.entry.Time,
+			r.entry.Actor,
+			r.entry.Command,
+			r.entry.Target,
+			r.entry.Outcome,
+			r.entry.Message,
+		)
+	}
+
+	logger.Info("%d matching entries", len(results))
+
+	return nil
+}
@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3Lib "github.com/aws/aws-sdk-go-v2/service/s3"
+	configPkg "github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+)
+
+// readLogs streams every line of the archived logs under prefixes, the rotated logs pending
+// upload and the current log, oldest first
+func readLogs(ctx context.Context, cfg aws.Config, prefixes []string, localOnly bool, fn func(source string, lineNumber int, line string)) error {
+	if !localOnly {
+		if len(configPkg.Config.AuditBucket) == 0 {
+			return fmt.Errorf("audit_bucket not specified in %s", configPkg.Filename)
+		}
+
+		s3Handler := s3Lib.NewFromConfig(cfg)
+
+		for _, prefix := range prefixes {
+			// keys are dt=YYYYMMDD/hour=HH/archive_log-<chain id>-<rotation time>-<suffix>, so the archives of
+			// every chain list in chronological order
+			paginator := s3Lib.NewListObjectsV2Paginator(s3Handler, &s3Lib.ListObjectsV2Input{
+				Bucket: aws.String(configPkg.Config.AuditBucket),
+				Prefix: aws.String(prefix),
+			})
+
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return err
+				}
+
+				for _, object := range page.Contents {
+					result, err := s3Handler.GetObject(ctx, &s3Lib.GetObjectInput{
+						Bucket: aws.String(configPkg.Config.AuditBucket),
+						Key:    object.Key,
+					})
+					if err != nil {
+						return err
+					}
+
+					source := fmt.Sprintf("s3://%s/%s", configPkg.Config.AuditBucket, aws.ToString(object.Key))
+					err = scanLines(result.Body, source, fn)
+					result.Body.Close()
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	filenames, err := getRotatedFilenames()
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(configPkg.Config.LocalLogFilename); err == nil {
+		filenames = append(filenames, configPkg.Config.LocalLogFilename)
+	}
+
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+
+		err = scanLines(file, filename, fn)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanLines(body io.Reader, source string, fn func(source string, lineNumber int, line string)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLogSize)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		fn(source, lineNumber, scanner.Text())
+	}
+
+	return scanner.Err()
+}
+
+// parseEntry returns false for lines which are not structured entries, like those written
+// before entries were hash chained
+func parseEntry(line string) (Entry, bool) {
+	var entry Entry
+	if !strings.HasPrefix(line, "{") {
+		return entry, false
+	}
+
+	if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Hash == "" {
+		return entry, false
+	}
+
+	return entry, true
+}
+
+// chainState is how far Verify has walked the hash chain of a host
+type chainState struct {
+	prevHash string
+	entries  int
+}
+
+// Verify walks the hash chain of every host across the archived and local logs and reports every
+// entry which was edited, removed or inserted
+func Verify(ctx context.Context, cfg aws.Config, localOnly bool) error {
+	entries, legacy, broken := 0, 0, 0
+	chains := make(map[string]*chainState)
+	sources := make(map[string]bool)
+
+	err := readLogs(ctx, cfg, []string{getArchivePrefix()}, localOnly, func(source string, lineNumber int, line string) {
+		sources[source] = true
+		location := fmt.Sprintf("%s:%d", source, lineNumber)
+
+		// the rotated and current logs on disk belong to the chain of this host
+		chainID := getChainID()
+		if strings.HasPrefix(source, "s3://") {
+			chainID = getArchiveChainID(source)
+		}
+
+		chain, ok := chains[chainID]
+		if !ok {
+			chain = &chainState{}
+			chains[chainID] = chain
+		}
+
+		entry, ok := parseEntry(line)
+		if !ok {
+			// unstructured lines are only expected before the first chained entry
+			if chain.entries == 0 {
+				legacy++
+				return
+			}
+
+			logger.Error("%s is not a chained entry", logger.Underline(location))
+			broken++
+			return
+		}
+
+		if entry.computeHash() != entry.Hash {
+			logger.Error("%s has been modified", logger.Underline(location))
+			broken++
+		}
+
+		// the first entry is trusted as the start of the chain, as older archives may have expired
+		if chain.entries > 0 && entry.PrevHash != chain.prevHash {
+			logger.Error("%s does not follow the previous entry, entries before it were removed or reordered", logger.Underline(location))
+			broken++
+		}
+
+		chain.prevHash = entry.Hash
+		chain.entries++
+		entries++
+	})
+	if err != nil {
+		return err
+	}
+
+	if chain, ok := chains[getChainID()]; ok && chain.entries > 0 {
+		if head, err := os.ReadFile(headFilename()); err == nil && strings.TrimSpace(string(head)) != chain.prevHash {
+			logger.Error("Last entry does not match %s, entries at the end were removed", logger.Underline(headFilename()))
+			broken++
+		}
+	}
+
+	if legacy > 0 {
+		logger.Warn("Skipped %d unchained line(s) written before the first chained entry", legacy)
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("audit log chain is broken at %d place(s) across %d entries", broken, entries)
+	}
+
+	logger.Success("Verified %d entries of %d host(s) across %d log file(s)", entries, len(chains), len(sources))
+
+	return nil
+}
@@ -3,31 +3,45 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/mudrex/onyx/pkg/filesystem"
 	"github.com/mudrex/onyx/pkg/logger"
 )
 
 type C struct {
-	Region                  string `json:"region"`
-	Environment             string `json:"environment"`
-	SlackHook               string `json:"slack_hook"`
-	VPCCidr                 string `json:"vpc_cidr"`
-	PrivateKey              string `json:"private_key"`
-	HostsAccessConfig       string `json:"hosts_access_config"`
-	ServicesAccessConfig    string `json:"services_access_config"`
-	RDSAccessConfig         string `json:"rds_access_config"`
-	RDSServicesAccessConfig string `json:"rds_services_access_config"`
-	RDSCriticalTablesConfig string `json:"rds_critical_tables_config"`
-	RDSSecretName           string `json:"rds_secret_name"`
-	AuditBucket             string `json:"audit_bucket"`
-	LocalLogFilename        string `json:"local_log_filename"`
-	ECSScaleUpConfig        string `json:"ecs_scale_up_config"`
-	OptimusSecretName       string `json:"optimus_secret_name"`
-	OptimusUsersConfig      string `json:"optimus_users_config"`
-	OptimusRolesConfig      string `json:"optimus_roles_config"`
-	OptimusJobsConfig		string `json:"optimus_jobs_config"`
-	CASecretName            string `json:"ca_secret_name"`
+	Region                  string                      `json:"region"`
+	Environment             string                      `json:"environment"`
+	SlackHook               string                      `json:"slack_hook"`
+	Notifiers               []NotifierConfig            `json:"notifiers"`
+	VPCCidr                 string                      `json:"vpc_cidr"`
+	PrivateKey              string                      `json:"private_key"`
+	TunnelHost              string                      `json:"tunnel_host"`
+	MaxCopySizeMB           int64                       `json:"max_copy_size_mb"`
+	HostsAccessConfig       string                      `json:"hosts_access_config"`
+	ServicesAccessConfig    string                      `json:"services_access_config"`
+	RDSAccessConfig         string                      `json:"rds_access_config"`
+	RDSServicesAccessConfig string                      `json:"rds_services_access_config"`
+	RDSCriticalTablesConfig string                      `json:"rds_critical_tables_config"`
+	RDSSecretName           string                      `json:"rds_secret_name"`
+	RDSSecrets              map[string]string           `json:"rds_secrets"`
+	RDSUserSecretTemplate   string                      `json:"rds_user_secret_template"`
+	AuditBucket             string                      `json:"audit_bucket"`
+	LocalLogFilename        string                      `json:"local_log_filename"`
+	ECSScaleUpConfig        string                      `json:"ecs_scale_up_config"`
+	ECSAutoscalingConfig    string                      `json:"ecs_autoscaling_config"`
+	ECSClusters             map[string]ECSClusterConfig `json:"ecs_clusters"`
+	SandstormConfig         string                      `json:"sandstorm_config"`
+	SandstormStateBucket    string                      `json:"sandstorm_state_bucket"`
+	ScheduleInstanceID      string                      `json:"schedule_instance_id"`
+	ScheduleRoleARN         string                      `json:"schedule_role_arn"`
+	ScheduleUser            string                      `json:"schedule_user"`
+	OptimusSecretName       string                      `json:"optimus_secret_name"`
+	OptimusUsersConfig      string                      `json:"optimus_users_config"`
+	OptimusRolesConfig      string                      `json:"optimus_roles_config"`
+	OptimusJobsConfig       string                      `json:"optimus_jobs_config"`
+	CASecretName            string                      `json:"ca_secret_name"`
 	CertificateSubject      struct {
 		Country            string `json:"country"`
 		Province           string `json:"province"`
@@ -37,6 +51,34 @@ type C struct {
 	} `json:"certificate_subject"`
 }
 
+// NotifierConfig is a sink notifications are sent to. Type is one of slack, teams, webhook, smtp or stdout.
+// Severities limits the sink to the given severities, empty means all.
+type NotifierConfig struct {
+	Type       string      `json:"type"`
+	URL        string      `json:"url,omitempty"`
+	Severities []string    `json:"severities,omitempty"`
+	SMTP       *SMTPConfig `json:"smtp,omitempty"`
+}
+
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// ECSClusterConfig holds the per cluster settings of ecs commands
+type ECSClusterConfig struct {
+	// ShellMode is either ssh, the default, which execs into the container through its host
+	// or exec, which uses ECS Exec and works on Fargate as well
+	ShellMode string `json:"shell_mode,omitempty"`
+	// LogsMode is either ssh, the default, which runs docker logs on a host
+	// or cloudwatch, which follows the awslogs streams of every task
+	LogsMode string `json:"logs_mode,omitempty"`
+}
+
 var Config C
 
 var Filename = ".onyx.json"
@@ -84,6 +126,15 @@ func GetRegion() string {
 	return Config.Region
 }
 
+// GetMaxCopySize returns the maximum size in bytes of files copied to and from hosts and containers
+func GetMaxCopySize() int64 {
+	if Config.MaxCopySizeMB <= 0 {
+		return 100 * 1024 * 1024
+	}
+
+	return Config.MaxCopySizeMB * 1024 * 1024
+}
+
 func SetConfigKey(key, value string) error {
 	configData, err := filesystem.ReadFile(Filename)
 	if err != nil {
@@ -109,6 +160,15 @@ func SetConfigKey(key, value string) error {
 		loadedConfig.VPCCidr = value
 	case "private_key":
 		loadedConfig.PrivateKey = value
+	case "tunnel_host":
+		loadedConfig.TunnelHost = value
+	case "max_copy_size_mb":
+		maxCopySizeMB, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxCopySizeMB <= 0 {
+			return fmt.Errorf("invalid max_copy_size_mb %s", value)
+		}
+
+		loadedConfig.MaxCopySizeMB = maxCopySizeMB
 	case "hosts_access_config":
 		loadedConfig.HostsAccessConfig = value
 	case "services_access_config":
@@ -121,6 +181,8 @@ func SetConfigKey(key, value string) error {
 		loadedConfig.RDSServicesAccessConfig = value
 	case "rds_critical_tables_config":
 		loadedConfig.RDSCriticalTablesConfig = value
+	case "rds_user_secret_template":
+		loadedConfig.RDSUserSecretTemplate = value
 	case "audit_bucket":
 		loadedConfig.AuditBucket = value
 	case "local_log_filename":
@@ -129,8 +191,60 @@ func SetConfigKey(key, value string) error {
 		loadedConfig.OptimusSecretName = value
 	case "ecs_scale_up_config":
 		loadedConfig.ECSScaleUpConfig = value
+	case "ecs_autoscaling_config":
+		loadedConfig.ECSAutoscalingConfig = value
+	case "sandstorm_config":
+		loadedConfig.SandstormConfig = value
+	case "sandstorm_state_bucket":
+		loadedConfig.SandstormStateBucket = value
+	case "schedule_instance_id":
+		loadedConfig.ScheduleInstanceID = value
+	case "schedule_role_arn":
+		loadedConfig.ScheduleRoleARN = value
+	case "schedule_user":
+		loadedConfig.ScheduleUser = value
 	default:
-		return fmt.Errorf("unrecognized key %s", logger.Underline(key))
+		switch {
+		// secrets of named databases are set as rds_secrets.<alias>
+		case strings.HasPrefix(key, "rds_secrets."):
+			if loadedConfig.RDSSecrets == nil {
+				loadedConfig.RDSSecrets = make(map[string]string)
+			}
+
+			loadedConfig.RDSSecrets[strings.TrimPrefix(key, "rds_secrets.")] = value
+		// cluster settings are set as ecs_clusters.<cluster>.<setting>
+		case strings.HasPrefix(key, "ecs_clusters."):
+			parts := strings.Split(strings.TrimPrefix(key, "ecs_clusters."), ".")
+			if len(parts) != 2 {
+				return fmt.Errorf("unrecognized key %s", logger.Underline(key))
+			}
+
+			if loadedConfig.ECSClusters == nil {
+				loadedConfig.ECSClusters = make(map[string]ECSClusterConfig)
+			}
+
+			clusterConfig := loadedConfig.ECSClusters[parts[0]]
+			switch parts[1] {
+			case "shell_mode":
+				if value != "ssh" && value != "exec" {
+					return fmt.Errorf("invalid shell_mode %s. Allowed ssh or exec", value)
+				}
+
+				clusterConfig.ShellMode = value
+			case "logs_mode":
+				if value != "ssh" && value != "cloudwatch" {
+					return fmt.Errorf("invalid logs_mode %s. Allowed ssh or cloudwatch", value)
+				}
+
+				clusterConfig.LogsMode = value
+			default:
+				return fmt.Errorf("unrecognized key %s", logger.Underline(key))
+			}
+
+			loadedConfig.ECSClusters[parts[0]] = clusterConfig
+		default:
+			return fmt.Errorf("unrecognized key %s", logger.Underline(key))
+		}
 	}
 
 	finalConfig, err := json.Marshal(loadedConfig)
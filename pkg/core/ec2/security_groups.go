@@ -7,17 +7,24 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	ec2Lib "github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/mudrex/onyx/pkg/audit"
 	configPkg "github.com/mudrex/onyx/pkg/config"
 	"github.com/mudrex/onyx/pkg/core/iam"
 	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
 	"github.com/mudrex/onyx/pkg/utils"
 )
 
+const onyxRulePrefix = "[Onyx approved] User: "
+
+const onyxRuleExpirySeparator = " Expires: "
+
 var reverseAllowedRules = map[int32]string{
 	22:    "ssh",
 	6379:  "redis",
@@ -51,6 +58,7 @@ type SecurityGroupRule struct {
 	cidr        string
 	protocol    string
 	description string
+	expiresAt   time.Time
 }
 
 type Filter struct {
@@ -136,11 +144,17 @@ func NewSecurityGroup(ctx context.Context, cfg aws.Config, id string) (*Security
 	return &(securityGroups[0]), nil
 }
 
-func NewSecurityGroupRule(port int32, user string) (*SecurityGroupRule, error) {
-	return &SecurityGroupRule{
+func NewSecurityGroupRule(port int32, user string, ttl time.Duration) (*SecurityGroupRule, error) {
+	rule := SecurityGroupRule{
 		port: port,
 		user: strings.ToLower(user),
-	}, nil
+	}
+
+	if ttl > 0 {
+		rule.expiresAt = time.Now().UTC().Add(ttl).Truncate(time.Second)
+	}
+
+	return &rule, nil
 }
 
 // DisplaySecurityGroup prints the security group details
@@ -184,11 +198,35 @@ func (sg *SecurityGroup) DisplaySecurityGroup(ctx context.Context, cfg aws.Confi
 }
 
 func (sgRule *SecurityGroupRule) enrichRuleDescription() string {
-	return fmt.Sprintf("[Onyx approved] User: %s", sgRule.user)
+	if sgRule.expiresAt.IsZero() {
+		return fmt.Sprintf("%s%s", onyxRulePrefix, sgRule.user)
+	}
+
+	return fmt.Sprintf("%s%s%s%s", onyxRulePrefix, sgRule.user, onyxRuleExpirySeparator, sgRule.expiresAt.Format(time.RFC3339))
 }
 
 func (sgRule *SecurityGroupRule) GetUserName() string {
-	return strings.Replace(sgRule.description, "[Onyx approved] User: ", "", -1)
+	return strings.Split(strings.Replace(sgRule.description, onyxRulePrefix, "", -1), onyxRuleExpirySeparator)[0]
+}
+
+// IsOnyxApproved tells if the rule was added by onyx
+func (sgRule *SecurityGroupRule) IsOnyxApproved() bool {
+	return strings.HasPrefix(sgRule.description, onyxRulePrefix)
+}
+
+// GetExpiry returns the expiry recorded in the rule description, if any
+func (sgRule *SecurityGroupRule) GetExpiry() (time.Time, bool) {
+	parts := strings.Split(sgRule.description, onyxRuleExpirySeparator)
+	if len(parts) != 2 {
+		return time.Time{}, false
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[1]))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return expiresAt, true
 }
 
 func (sgRule *SecurityGroupRule) attachNewIP(ip string) {
@@ -542,6 +580,7 @@ func AuthorizeOrRevokeRule(
 	filters []string,
 	skipChoice,
 	authorize bool,
+	ttl time.Duration,
 ) error {
 	filtersToApply := make([]Filter, 0)
 	if len(filters) > 0 {
@@ -582,6 +621,10 @@ func AuthorizeOrRevokeRule(
 		return errors.New("no ports to authorize")
 	}
 
+	if ttl < 0 {
+		return errors.New("ttl can not be negative")
+	}
+
 	securityGroupUser, err := iam.Whoami()
 	if err != nil {
 		return errors.New("Unable to derive username. Error: " + err.Error())
@@ -659,6 +702,10 @@ func AuthorizeOrRevokeRule(
 
 	publicIP := utils.GetPublicIP()
 
+	if authorize && ttl > 0 {
+		logger.Info("Rules will expire in %s and be revoked by %s", logger.Bold(ttl), logger.Underline("onyx ec2 sg reap"))
+	}
+
 	for _, sgAlter := range securityGroups {
 		ports := make([]int32, 0)
 		for port := range sgAlter.Ports {
@@ -669,7 +716,7 @@ func AuthorizeOrRevokeRule(
 
 		sgRules := make([]SecurityGroupRule, 0)
 		for port := range sgAlter.Ports {
-			sgRule, _ := NewSecurityGroupRule(port, securityGroupUser)
+			sgRule, _ := NewSecurityGroupRule(port, securityGroupUser, ttl)
 			sgRules = append(sgRules, *sgRule)
 		}
 
@@ -690,3 +737,101 @@ func AuthorizeOrRevokeRule(
 
 	return nil
 }
+
+// ReapExpiredRules revokes all onyx approved rules whose expiry has passed
+func ReapExpiredRules(ctx context.Context, cfg aws.Config, env string, dryRun bool) error {
+	securityGroups, err := ListSecurityGroupsByEnv(ctx, cfg, env)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reaped := make([]string, 0)
+	failed := make([]string, 0)
+
+	for _, securityGroup := range securityGroups {
+		expiredRulesByPort := make(map[int32][]SecurityGroupRule)
+		for _, rule := range securityGroup.rules {
+			if !rule.IsOnyxApproved() {
+				continue
+			}
+
+			expiresAt, ok := rule.GetExpiry()
+			if !ok || expiresAt.After(now) {
+				continue
+			}
+
+			expiredRulesByPort[rule.port] = append(expiredRulesByPort[rule.port], rule)
+		}
+
+		for port, rules := range expiredRulesByPort {
+			for _, rule := range rules {
+				expiresAt, _ := rule.GetExpiry()
+				logger.Info(
+					"%s (%s) | %d: %s of %s expired at %s",
+					logger.Bold(securityGroup.Name),
+					securityGroup.ID,
+					port,
+					rule.cidr,
+					logger.Underline(rule.GetUserName()),
+					expiresAt.Format(time.RFC3339),
+				)
+			}
+
+			if dryRun {
+				continue
+			}
+
+			err := securityGroup.Revoke(ctx, cfg, rules, "", false)
+			for _, rule := range rules {
+				entry := fmt.Sprintf("%s (%s) %d %s", securityGroup.Name, securityGroup.ID, port, rule.GetUserName())
+				if err != nil {
+					failed = append(failed, entry)
+				} else {
+					reaped = append(reaped, entry)
+				}
+			}
+
+			if err != nil {
+				logger.Error("Unable to revoke expired rules for %s (%s). Error: %s", securityGroup.Name, securityGroup.ID, err.Error())
+			}
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	if len(reaped) == 0 && len(failed) == 0 {
+		logger.Success("No expired rules found")
+		return nil
+	}
+
+	message := fmt.Sprintf("[ec2/sg-reap] Revoked %d expired rule(s)", len(reaped))
+	if len(reaped) > 0 {
+		message += "\n" + strings.Join(reaped, "\n")
+	}
+
+	if len(failed) > 0 {
+		message += fmt.Sprintf("\n:bangbang: Failed to revoke %d expired rule(s)\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+
+	outcome := audit.OutcomeSuccess
+	if len(failed) > 0 {
+		outcome = audit.OutcomeFailure
+	}
+
+	severity := notifier.SeverityInfo
+	if len(failed) > 0 {
+		severity = notifier.SeverityCritical
+	}
+
+	notifier.Notify(severity, message)
+	audit.Log(ctx, audit.Entry{Command: "ec2/sg-reap", Target: env, Outcome: outcome, Message: message})
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to revoke %d expired rule(s)", len(failed))
+	}
+
+	return nil
+}
@@ -0,0 +1,445 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/filesystem"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// TargetTrackingPolicy is a target tracking policy of a service in ecs_autoscaling_config
+type TargetTrackingPolicy struct {
+	Name string `json:"name"`
+	// Metric is one of cpu, memory or alb_request_count
+	Metric      string  `json:"metric"`
+	TargetValue float64 `json:"target_value"`
+	// ResourceLabel is only needed for alb_request_count, as app/<alb-name>/<alb-id>/targetgroup/<tg-name>/<tg-id>
+	ResourceLabel    string `json:"resource_label,omitempty"`
+	ScaleInCooldown  int32  `json:"scale_in_cooldown"`
+	ScaleOutCooldown int32  `json:"scale_out_cooldown"`
+	DisableScaleIn   bool   `json:"disable_scale_in"`
+}
+
+var policyMetrics = map[string]types.MetricType{
+	"cpu":               types.MetricTypeECSServiceAverageCPUUtilization,
+	"memory":            types.MetricTypeECSServiceAverageMemoryUtilization,
+	"alb_request_count": types.MetricTypeALBRequestCountPerTarget,
+}
+
+func (p TargetTrackingPolicy) validate() error {
+	if p.Name == "" {
+		return errors.New("policies need a name")
+	}
+
+	if _, ok := policyMetrics[p.Metric]; !ok {
+		return fmt.Errorf("invalid metric %s of %s, expected cpu, memory or alb_request_count", p.Metric, p.Name)
+	}
+
+	if p.Metric == "alb_request_count" && p.ResourceLabel == "" {
+		return fmt.Errorf("%s needs the resource_label of its target group", p.Name)
+	}
+
+	if p.TargetValue <= 0 {
+		return fmt.Errorf("%s needs a positive target_value", p.Name)
+	}
+
+	return nil
+}
+
+func (p TargetTrackingPolicy) describe() string {
+	description := fmt.Sprintf("%s %g, cooldown in/out %ds/%ds", p.Metric, p.TargetValue, p.ScaleInCooldown, p.ScaleOutCooldown)
+	if p.DisableScaleIn {
+		description += ", scale in disabled"
+	}
+
+	return description
+}
+
+// getTargetTrackingPolicy returns the policy as it would be declared in ecs_autoscaling_config, false for
+// step policies and target tracking on metrics other than cpu, memory and alb_request_count
+func getTargetTrackingPolicy(policy types.ScalingPolicy) (TargetTrackingPolicy, bool) {
+	configuration := policy.TargetTrackingScalingPolicyConfiguration
+	if configuration == nil || configuration.PredefinedMetricSpecification == nil {
+		return TargetTrackingPolicy{}, false
+	}
+
+	for metric, metricType := range policyMetrics {
+		if metricType != configuration.PredefinedMetricSpecification.PredefinedMetricType {
+			continue
+		}
+
+		return TargetTrackingPolicy{
+			Name:             aws.ToString(policy.PolicyName),
+			Metric:           metric,
+			TargetValue:      aws.ToFloat64(configuration.TargetValue),
+			ResourceLabel:    aws.ToString(configuration.PredefinedMetricSpecification.ResourceLabel),
+			ScaleInCooldown:  aws.ToInt32(configuration.ScaleInCooldown),
+			ScaleOutCooldown: aws.ToInt32(configuration.ScaleOutCooldown),
+			DisableScaleIn:   aws.ToBool(configuration.DisableScaleIn),
+		}, true
+	}
+
+	return TargetTrackingPolicy{}, false
+}
+
+// loadAutoscalingConfig returns the policies of ecs_autoscaling_config keyed by cluster and service
+func loadAutoscalingConfig() (map[string]map[string][]TargetTrackingPolicy, error) {
+	if config.Config.ECSAutoscalingConfig == "" {
+		return nil, errors.New("ecs_autoscaling_config is not set in onyx config")
+	}
+
+	configData, err := filesystem.ReadFile(config.Config.ECSAutoscalingConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make(map[string]map[string][]TargetTrackingPolicy)
+	if err := json.Unmarshal([]byte(configData), &policies); err != nil {
+		return nil, err
+	}
+
+	for cluster, services := range policies {
+		for service, servicePolicies := range services {
+			for _, policy := range servicePolicies {
+				if err := policy.validate(); err != nil {
+					return nil, fmt.Errorf("%s (%s): %s", service, cluster, err.Error())
+				}
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+func getScalingPolicies(ctx context.Context, cfg aws.Config, resourceID string) ([]types.ScalingPolicy, error) {
+	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
+
+	policies := make([]types.ScalingPolicy, 0)
+	paginator := applicationautoscaling.NewDescribeScalingPoliciesPaginator(autoscalingHandler, &applicationautoscaling.DescribeScalingPoliciesInput{
+		ServiceNamespace:  types.ServiceNamespaceEcs,
+		ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+		ResourceId:        aws.String(resourceID),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		policies = append(policies, output.ScalingPolicies...)
+	}
+
+	return policies, nil
+}
+
+func formatStepAdjustments(configuration *types.StepScalingPolicyConfiguration) string {
+	if configuration == nil {
+		return "-"
+	}
+
+	steps := make([]string, 0)
+	for _, step := range configuration.StepAdjustments {
+		lower, upper := "-inf", "+inf"
+		if step.MetricIntervalLowerBound != nil {
+			lower = fmt.Sprint(aws.ToFloat64(step.MetricIntervalLowerBound))
+		}
+
+		if step.MetricIntervalUpperBound != nil {
+			upper = fmt.Sprint(aws.ToFloat64(step.MetricIntervalUpperBound))
+		}
+
+		steps = append(steps, fmt.Sprintf("[%s, %s) %+d", lower, upper, aws.ToInt32(step.ScalingAdjustment)))
+	}
+
+	return fmt.Sprintf("%s %s, cooldown %ds", configuration.AdjustmentType, strings.Join(steps, " "), aws.ToInt32(configuration.Cooldown))
+}
+
+func describeScalingPolicy(policy types.ScalingPolicy) string {
+	if targetTrackingPolicy, ok := getTargetTrackingPolicy(policy); ok {
+		return targetTrackingPolicy.describe()
+	}
+
+	if configuration := policy.TargetTrackingScalingPolicyConfiguration; configuration != nil {
+		return fmt.Sprintf("custom metric %g", aws.ToFloat64(configuration.TargetValue))
+	}
+
+	return formatStepAdjustments(policy.StepScalingPolicyConfiguration)
+}
+
+// ShowAutoscaling prints the scalable target, the scaling policies and the latest scaling activities of the service
+func ShowAutoscaling(ctx context.Context, cfg aws.Config, clusterName, serviceName string, activities int) error {
+	if activities > 50 {
+		return errors.New("please limit your activities to 50")
+	}
+
+	serviceName, err := resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	counts, err := getServiceCounts(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s (%s)\n", logger.Bold(serviceName), clusterName)
+
+	if !counts.Scalable {
+		logger.Warn("%s has no autoscaling target, desired %d", serviceName, counts.DesiredCount)
+		return nil
+	}
+
+	fmt.Printf("  min %d, max %d, desired %d\n\n", counts.MinCount, counts.MaxCount, counts.DesiredCount)
+
+	policies, err := getScalingPolicies(ctx, cfg, getCountsResourceID(counts))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(logger.Underline("Policies"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tTYPE\tCONFIGURATION\tALARMS\t")
+	for _, policy := range policies {
+		alarms := make([]string, 0)
+		for _, alarm := range policy.Alarms {
+			alarms = append(alarms, aws.ToString(alarm.AlarmName))
+		}
+
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t\n", aws.ToString(policy.PolicyName), policy.PolicyType, describeScalingPolicy(policy), orDash(strings.Join(alarms, ", ")))
+	}
+	w.Flush()
+
+	if activities <= 0 {
+		return nil
+	}
+
+	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
+	output, err := autoscalingHandler.DescribeScalingActivities(ctx, &applicationautoscaling.DescribeScalingActivitiesInput{
+		ServiceNamespace:  types.ServiceNamespaceEcs,
+		ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+		ResourceId:        aws.String(getCountsResourceID(counts)),
+		MaxResults:        aws.Int32(int32(activities)),
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println(logger.Underline("Scaling activities"))
+	for _, activity := range output.ScalingActivities {
+		fmt.Printf("  %s %s %s\n", formatTime(activity.StartTime), colorStatus(string(activity.StatusCode)), aws.ToString(activity.Description))
+		fmt.Println("    ", logger.Italic(aws.ToString(activity.Cause)))
+		if activity.StatusMessage != nil {
+			fmt.Println("    ", aws.ToString(activity.StatusMessage))
+		}
+	}
+
+	return nil
+}
+
+type policyChange struct {
+	ClusterName string
+	ServiceName string
+	From        *TargetTrackingPolicy
+	To          TargetTrackingPolicy
+	Err         error
+}
+
+// describe returns the change as it is recorded in the audit log
+func (c policyChange) describe() string {
+	from := "none"
+	if c.From != nil {
+		from = c.From.describe()
+	}
+
+	description := fmt.Sprintf("%s (%s) %s: %s -> %s", c.ServiceName, c.ClusterName, c.To.Name, from, c.To.describe())
+	if c.Err != nil {
+		description += ", failed: " + c.Err.Error()
+	}
+
+	return description
+}
+
+// SetAutoscaling creates or updates the target tracking policies of ecs_autoscaling_config on the scalable
+// targets of its services, only of the cluster and service if they are set. Policies missing from the file are
+// left as they are. With dryRun only the planned changes are printed.
+func SetAutoscaling(ctx context.Context, cfg aws.Config, clusterName, serviceName string, dryRun bool) error {
+	autoscalingConfig, err := loadAutoscalingConfig()
+	if err != nil {
+		return err
+	}
+
+	clusters := make([]string, 0)
+	for cluster := range autoscalingConfig {
+		if clusterName == "" || cluster == clusterName {
+			clusters = append(clusters, cluster)
+		}
+	}
+	sort.Strings(clusters)
+
+	changes := make([]policyChange, 0)
+	for _, cluster := range clusters {
+		services := make([]string, 0)
+		for service := range autoscalingConfig[cluster] {
+			if serviceName == "" || service == serviceName {
+				services = append(services, service)
+			}
+		}
+		sort.Strings(services)
+
+		for _, service := range services {
+			counts, err := getServiceCounts(ctx, cfg, cluster, service)
+			if err == nil && !counts.Scalable {
+				err = errors.New("no autoscaling target, run ecs scale first")
+			}
+
+			var existing []types.ScalingPolicy
+			if err == nil {
+				existing, err = getScalingPolicies(ctx, cfg, getCountsResourceID(counts))
+			}
+
+			if err != nil {
+				logger.Error("%s (%s) | Error: %s", logger.Red(logger.Underline(service)), cluster, err.Error())
+				for _, policy := range autoscalingConfig[cluster][service] {
+					changes = append(changes, policyChange{ClusterName: cluster, ServiceName: service, To: policy, Err: err})
+				}
+
+				continue
+			}
+
+			declared := make(map[string]bool)
+			for _, policy := range autoscalingConfig[cluster][service] {
+				declared[policy.Name] = true
+
+				change := policyChange{ClusterName: cluster, ServiceName: service, To: policy}
+				for _, existingPolicy := range existing {
+					if aws.ToString(existingPolicy.PolicyName) != policy.Name {
+						continue
+					}
+
+					if current, ok := getTargetTrackingPolicy(existingPolicy); ok {
+						change.From = &current
+					} else {
+						change.Err = fmt.Errorf("%s exists as a %s policy", policy.Name, existingPolicy.PolicyType)
+					}
+				}
+
+				changes = append(changes, change)
+			}
+
+			for _, existingPolicy := range existing {
+				if !declared[aws.ToString(existingPolicy.PolicyName)] {
+					logger.Warn("%s (%s) | %s is not in %s and is left as is", service, cluster, aws.ToString(existingPolicy.PolicyName), config.Config.ECSAutoscalingConfig)
+				}
+			}
+		}
+	}
+
+	if len(changes) == 0 {
+		return fmt.Errorf("no policies found in %s", config.Config.ECSAutoscalingConfig)
+	}
+
+	logger.Info("Planned autoscaling changes")
+	pending := 0
+	for _, change := range changes {
+		fmt.Printf("%s (%s) %s\n", logger.Underline(change.ServiceName), change.ClusterName, logger.Bold(change.To.Name))
+		switch {
+		case change.Err != nil:
+			fmt.Println("  ", logger.Red(change.Err.Error()))
+		case change.From == nil:
+			fmt.Println("  ", logger.Green("create"), change.To.describe())
+			pending++
+		case *change.From == change.To:
+			fmt.Println("  ", logger.Italic("unchanged"))
+		default:
+			fmt.Println("  ", logger.Italic(change.From.describe()))
+			fmt.Println("  ", logger.Bold(change.To.describe()))
+			pending++
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
+
+	failed := 0
+	applied := make([]int, 0)
+	for i, change := range changes {
+		if change.Err != nil {
+			failed++
+			continue
+		}
+
+		if change.From != nil && *change.From == change.To {
+			continue
+		}
+
+		applied = append(applied, i)
+
+		metric := &types.PredefinedMetricSpecification{PredefinedMetricType: policyMetrics[change.To.Metric]}
+		if change.To.ResourceLabel != "" {
+			metric.ResourceLabel = aws.String(change.To.ResourceLabel)
+		}
+
+		_, err := autoscalingHandler.PutScalingPolicy(ctx, &applicationautoscaling.PutScalingPolicyInput{
+			PolicyName:        aws.String(change.To.Name),
+			PolicyType:        types.PolicyTypeTargetTrackingScaling,
+			ResourceId:        aws.String("service/" + change.ClusterName + "/" + change.ServiceName),
+			ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+			ServiceNamespace:  types.ServiceNamespaceEcs,
+			TargetTrackingScalingPolicyConfiguration: &types.TargetTrackingScalingPolicyConfiguration{
+				TargetValue:                   aws.Float64(change.To.TargetValue),
+				PredefinedMetricSpecification: metric,
+				ScaleInCooldown:               aws.Int32(change.To.ScaleInCooldown),
+				ScaleOutCooldown:              aws.Int32(change.To.ScaleOutCooldown),
+				DisableScaleIn:                aws.Bool(change.To.DisableScaleIn),
+			},
+		})
+		if err != nil {
+			changes[i].Err = err
+			failed++
+			logger.Error("%s -> %s (%s) | Error: %s", logger.Bold(change.To.Name), logger.Red(logger.Underline(change.ServiceName)), change.ClusterName, err.Error())
+			continue
+		}
+
+		logger.Success("%s -> %s (%s)", logger.Bold(change.To.Name), logger.Underline(change.ServiceName), change.ClusterName)
+	}
+
+	// the audit log records every policy that was put with the values it had before
+	descriptions := make([]string, 0)
+	for _, i := range applied {
+		descriptions = append(descriptions, changes[i].describe())
+	}
+
+	details := strings.Join(descriptions, "; ")
+
+	if failed > 0 {
+		log := fmt.Sprintf(":bangbang: [ecs/autoscaling] *%s* applied %s, %d of %d policies failed", utils.GetUser(), config.Config.ECSAutoscalingConfig, failed, len(changes))
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "ecs/autoscaling", Target: config.Config.ECSAutoscalingConfig, Outcome: audit.OutcomeFailure, Message: log + " | " + details})
+
+		return fmt.Errorf("%d of %d policies failed", failed, len(changes))
+	}
+
+	log := fmt.Sprintf("[ecs/autoscaling] *%s* applied %s, %d of %d policies changed", utils.GetUser(), config.Config.ECSAutoscalingConfig, pending, len(changes))
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/autoscaling", Target: config.Config.ECSAutoscalingConfig, Outcome: audit.OutcomeSuccess, Message: log + " | " + details})
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/auth"
+	"github.com/mudrex/onyx/pkg/config"
+	sshPkg "github.com/mudrex/onyx/pkg/core/ssh"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// Copy copies a file between the local machine and a container of the service, given as <service>:<path>
+// on either side, through the container's host. The container and task are chosen like port-forward does.
+func Copy(ctx context.Context, cfg aws.Config, clusterName, containerName, taskID, source, destination string) error {
+	if !strings.Contains(clusterName, config.Config.Environment) {
+		logger.Error("You are in %s environment but you are trying to access %s environment", logger.Underline(config.Config.Environment), clusterName)
+		return nil
+	}
+
+	serviceName, remotePath, localPath, fromContainer, err := sshPkg.ParseCopyArgs(source, destination)
+	if err != nil {
+		return err
+	}
+
+	serviceName, err = resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	currUser, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	username := currUser.Username
+
+	isAuthorized, err := auth.CheckUserAccessForService(ctx, username, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		log := fmt.Sprintf("[ecs/cp] *%s* is not authorized to copy %s to %s", utils.GetUser(), source, destination)
+		audit.Log(ctx, audit.Entry{Command: "ecs/cp", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeDenied, Message: log})
+
+		logger.Error("%s is not authorized to access %s", logger.Underline(username), logger.Bold(serviceName))
+		return nil
+	}
+
+	allTargets, err := getExecTargets(ctx, cfg, clusterName, serviceName, containerName, taskID)
+	if err != nil {
+		return err
+	}
+
+	// only the exact service the user is authorized for, not the others matching its name
+	targets := make([]execTarget, 0)
+	for _, target := range allTargets {
+		if target.ServiceName == serviceName {
+			targets = append(targets, target)
+		}
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no running task of %s found", logger.Underline(serviceName))
+	}
+
+	target, err := selectExecTarget(targets)
+	if err != nil {
+		return err
+	}
+
+	if target.ContainerInstanceArn == "" || target.RuntimeID == "" {
+		return fmt.Errorf("task %s has no container instance to copy through", target.TaskID())
+	}
+
+	hosts, err := getContainerInstanceIPs(ctx, cfg, clusterName, []string{target.ContainerInstanceArn})
+	if err != nil {
+		return err
+	}
+
+	host := hosts[target.ContainerInstanceArn]
+	if host == "" {
+		return fmt.Errorf("unable to find the host of task %s", target.TaskID())
+	}
+
+	userHost := "ec2-user@" + host
+	containerID := target.RuntimeID
+
+	logger.Info("Copying %s to %s through %s", logger.Bold(source), logger.Bold(destination), logger.Underline(host))
+
+	var transfer sshPkg.Transfer
+	if fromContainer {
+		transfer, err = sshPkg.CopyFromRemote(userHost, containerID, remotePath, localPath)
+	} else {
+		transfer, err = sshPkg.CopyToRemote(userHost, containerID, localPath, remotePath)
+	}
+
+	if err != nil {
+		log := fmt.Sprintf("[ecs/cp] *%s* failed to copy %s to %s on _%s_: %s", utils.GetUser(), source, destination, host, err.Error())
+		audit.Log(ctx, audit.Entry{Command: "ecs/cp", Target: serviceName + "@" + host, Outcome: audit.OutcomeFailure, Message: log})
+
+		return err
+	}
+
+	log := fmt.Sprintf("[ecs/cp] *%s* copied %s to %s on _%s_ (%s)", utils.GetUser(), source, destination, host, transfer.String())
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/cp", Target: serviceName + "@" + host, Outcome: audit.OutcomeSuccess, Message: log})
+
+	logger.Success("Copied %s to %s (%s)", source, destination, transfer.String())
+
+	return nil
+}
@@ -0,0 +1,286 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+const deployPollInterval = 10 * time.Second
+
+// deploymentOutcome is how watching a deployment ended
+type deploymentOutcome int
+
+const (
+	deploymentStable deploymentOutcome = iota
+	deploymentFailed
+	// deploymentSuperseded is a deployment replaced by a newer one, which must not be reverted
+	deploymentSuperseded
+)
+
+// replaceImageTag returns image with its tag, or digest, replaced by tag
+func replaceImageTag(image, tag string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+
+	repository, _ := splitImageTag(image)
+
+	return repository + ":" + tag
+}
+
+// registerTaskDefinitionRevision registers a new revision of the family of taskDefinition with the same settings
+func registerTaskDefinitionRevision(ctx context.Context, cfg aws.Config, taskDefinition *types.TaskDefinition, tags []types.Tag) (*types.TaskDefinition, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	input := &ecsLib.RegisterTaskDefinitionInput{
+		ContainerDefinitions:    taskDefinition.ContainerDefinitions,
+		Family:                  taskDefinition.Family,
+		Cpu:                     taskDefinition.Cpu,
+		ExecutionRoleArn:        taskDefinition.ExecutionRoleArn,
+		InferenceAccelerators:   taskDefinition.InferenceAccelerators,
+		IpcMode:                 taskDefinition.IpcMode,
+		Memory:                  taskDefinition.Memory,
+		NetworkMode:             taskDefinition.NetworkMode,
+		PidMode:                 taskDefinition.PidMode,
+		PlacementConstraints:    taskDefinition.PlacementConstraints,
+		ProxyConfiguration:      taskDefinition.ProxyConfiguration,
+		RequiresCompatibilities: taskDefinition.RequiresCompatibilities,
+		TaskRoleArn:             taskDefinition.TaskRoleArn,
+		Volumes:                 taskDefinition.Volumes,
+	}
+
+	if len(tags) > 0 {
+		input.Tags = tags
+	}
+
+	output, err := ecsHandler.RegisterTaskDefinition(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return output.TaskDefinition, nil
+}
+
+// Deploy registers a revision of the service's task definition with the container's image tag replaced,
+// updates the service to it and watches the rollout, reverting to the previous revision if it fails or times out
+func Deploy(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName, tag string, timeout time.Duration, skipChoice bool) error {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	serviceName, err := resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	servicesOutput, err := ecsHandler.DescribeServices(ctx, &ecsLib.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: []string{serviceName},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(servicesOutput.Services) == 0 {
+		return fmt.Errorf("no service %s found", logger.Underline(serviceName))
+	}
+
+	previousTaskDefinitionArn := aws.ToString(servicesOutput.Services[0].TaskDefinition)
+
+	taskDefinitionOutput, err := ecsHandler.DescribeTaskDefinition(ctx, &ecsLib.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(previousTaskDefinitionArn),
+		Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
+	})
+	if err != nil {
+		return err
+	}
+
+	taskDefinition := taskDefinitionOutput.TaskDefinition
+
+	current := newTaskDefinition(aws.ToString(taskDefinition.Family), taskDefinition)
+	containerName, err = current.ResolveContainer(serviceName, containerName)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	for j, containerDefinition := range taskDefinition.ContainerDefinitions {
+		if aws.ToString(containerDefinition.Name) == containerName {
+			i = j
+		}
+	}
+
+	oldImage := aws.ToString(taskDefinition.ContainerDefinitions[i].Image)
+	newImage := replaceImageTag(oldImage, tag)
+	if oldImage == newImage {
+		logger.Success("%s is already running %s", logger.Underline(serviceName), logger.Bold(newImage))
+		return nil
+	}
+
+	logger.Info(
+		"Will deploy %s/%s container %s: %s -> %s",
+		logger.Underline(clusterName),
+		logger.Underline(serviceName),
+		logger.Bold(aws.ToString(taskDefinition.ContainerDefinitions[i].Name)),
+		logger.Italic(oldImage),
+		logger.Bold(newImage),
+	)
+
+	if !skipChoice {
+		shouldDo := logger.InfoScan("Choose y/n: ")
+		if shouldDo != "y" {
+			logger.Success("Nothing to do")
+			return nil
+		}
+	}
+
+	taskDefinition.ContainerDefinitions[i].Image = aws.String(newImage)
+
+	newTaskDefinition, err := registerTaskDefinitionRevision(ctx, cfg, taskDefinition, taskDefinitionOutput.Tags)
+	if err != nil {
+		return err
+	}
+
+	newTaskDefinitionArn := aws.ToString(newTaskDefinition.TaskDefinitionArn)
+	logger.Info("Registered %s:%d", aws.ToString(newTaskDefinition.Family), newTaskDefinition.Revision)
+
+	startedAt := time.Now()
+	_, err = ecsHandler.UpdateService(ctx, &ecsLib.UpdateServiceInput{
+		Cluster:        aws.String(clusterName),
+		Service:        aws.String(serviceName),
+		TaskDefinition: aws.String(newTaskDefinitionArn),
+	})
+	if err != nil {
+		return err
+	}
+
+	log := fmt.Sprintf("[ecs/deploy] *%s* deployed %s to _%s/%s_", utils.GetUser(), newImage, clusterName, serviceName)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/deploy", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeSuccess, Message: log})
+
+	outcome, reason := watchDeployment(ctx, cfg, clusterName, serviceName, newTaskDefinitionArn, startedAt, timeout)
+	switch outcome {
+	case deploymentStable:
+		logger.Success("Deployed %s to %s/%s", logger.Bold(newImage), logger.Underline(clusterName), logger.Underline(serviceName))
+		return nil
+	case deploymentSuperseded:
+		log = fmt.Sprintf("[ecs/deploy] deployment of %s to _%s/%s_ by *%s* was superseded by a newer deployment", newImage, clusterName, serviceName, utils.GetUser())
+		notifier.Notify(notifier.SeverityInfo, log)
+		audit.Log(ctx, audit.Entry{Command: "ecs/deploy", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeSuccess, Message: log})
+
+		logger.Warn("Deployment of %s was superseded by a newer deployment, not reverting", logger.Bold(newImage))
+		return nil
+	}
+
+	// the context is done when the watch was interrupted, in which case there is nothing to revert with
+	if ctx.Err() != nil {
+		return fmt.Errorf("stopped watching the deployment of %s: %s", newImage, reason)
+	}
+
+	logger.Error("Deployment of %s failed: %s. Reverting to %s", logger.Bold(newImage), reason, logger.Italic(previousTaskDefinitionArn))
+
+	_, revertErr := ecsHandler.UpdateService(ctx, &ecsLib.UpdateServiceInput{
+		Cluster:        aws.String(clusterName),
+		Service:        aws.String(serviceName),
+		TaskDefinition: aws.String(previousTaskDefinitionArn),
+	})
+
+	log = fmt.Sprintf(":bangbang: [ecs/deploy] deployment of %s to _%s/%s_ by *%s* failed: %s. Reverted to %s", newImage, clusterName, serviceName, utils.GetUser(), reason, previousTaskDefinitionArn)
+	if revertErr != nil {
+		log = fmt.Sprintf(":bangbang: [ecs/deploy] deployment of %s to _%s/%s_ by *%s* failed: %s. Unable to revert to %s: %s", newImage, clusterName, serviceName, utils.GetUser(), reason, previousTaskDefinitionArn, revertErr.Error())
+	}
+
+	notifier.Notify(notifier.SeverityCritical, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/deploy", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeFailure, Message: log})
+
+	if revertErr != nil {
+		return fmt.Errorf("deployment failed: %s. Unable to revert: %s", reason, revertErr.Error())
+	}
+
+	return fmt.Errorf("deployment failed: %s. Reverted to %s", reason, previousTaskDefinitionArn)
+}
+
+// watchDeployment prints the service events until the deployment of taskDefinitionArn is stable, is replaced
+// by another deployment or fails, returning the reason if it failed, timed out or the context is done
+func watchDeployment(ctx context.Context, cfg aws.Config, clusterName, serviceName, taskDefinitionArn string, startedAt time.Time, timeout time.Duration) (deploymentOutcome, string) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	seenEvents := make(map[string]bool)
+	deadline := startedAt.Add(timeout)
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return deploymentFailed, ctx.Err().Error()
+		case <-time.After(deployPollInterval):
+		}
+
+		servicesOutput, err := ecsHandler.DescribeServices(ctx, &ecsLib.DescribeServicesInput{
+			Cluster:  aws.String(clusterName),
+			Services: []string{serviceName},
+		})
+		if err != nil || len(servicesOutput.Services) == 0 {
+			logger.Warn("Unable to describe %s, retrying", serviceName)
+			continue
+		}
+
+		service := servicesOutput.Services[0]
+
+		// events are returned newest first
+		for j := len(service.Events) - 1; j >= 0; j-- {
+			event := service.Events[j]
+			if seenEvents[aws.ToString(event.Id)] || event.CreatedAt == nil || event.CreatedAt.Before(startedAt) {
+				continue
+			}
+
+			seenEvents[aws.ToString(event.Id)] = true
+			fmt.Println(logger.Italic(event.CreatedAt.Format("15:04:05")), aws.ToString(event.Message))
+		}
+
+		var deployment *types.Deployment
+		for k := range service.Deployments {
+			if aws.ToString(service.Deployments[k].TaskDefinition) == taskDefinitionArn {
+				deployment = &service.Deployments[k]
+				break
+			}
+		}
+
+		if deployment == nil {
+			return deploymentSuperseded, ""
+		}
+
+		logger.Info(
+			"%s: running %d, pending %d, desired %d, failed %d",
+			logger.Bold(string(deployment.RolloutState)),
+			deployment.RunningCount,
+			deployment.PendingCount,
+			deployment.DesiredCount,
+			deployment.FailedTasks,
+		)
+
+		switch deployment.RolloutState {
+		case types.DeploymentRolloutStateCompleted:
+			return deploymentStable, ""
+		case types.DeploymentRolloutStateFailed:
+			return deploymentFailed, aws.ToString(deployment.RolloutStateReason)
+		case types.DeploymentRolloutStateInProgress:
+			continue
+		}
+
+		// services without the rolling update rollout state are stable once the new deployment
+		// is the only one and all its tasks are running
+		if len(service.Deployments) == 1 && deployment.RunningCount == deployment.DesiredCount {
+			return deploymentStable, ""
+		}
+	}
+
+	return deploymentFailed, fmt.Sprintf("timed out after %s", timeout)
+}
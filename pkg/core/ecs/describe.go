@@ -0,0 +1,441 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mudrex/onyx/pkg/core/ec2"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+type ServiceStatus struct {
+	Cluster        string             `json:"cluster"`
+	Name           string             `json:"name"`
+	Status         string             `json:"status"`
+	TaskDefinition string             `json:"task_definition"`
+	DesiredCount   int32              `json:"desired_count"`
+	RunningCount   int32              `json:"running_count"`
+	PendingCount   int32              `json:"pending_count"`
+	Deployments    []DeploymentStatus `json:"deployments"`
+	Events         []ServiceEvent     `json:"events"`
+	Tasks          []TaskStatus       `json:"tasks"`
+}
+
+type DeploymentStatus struct {
+	ID                 string     `json:"id"`
+	Status             string     `json:"status"`
+	TaskDefinition     string     `json:"task_definition"`
+	RolloutState       string     `json:"rollout_state,omitempty"`
+	RolloutStateReason string     `json:"rollout_state_reason,omitempty"`
+	DesiredCount       int32      `json:"desired_count"`
+	RunningCount       int32      `json:"running_count"`
+	PendingCount       int32      `json:"pending_count"`
+	FailedTasks        int32      `json:"failed_tasks"`
+	CreatedAt          *time.Time `json:"created_at,omitempty"`
+	UpdatedAt          *time.Time `json:"updated_at,omitempty"`
+}
+
+type ServiceEvent struct {
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Message   string     `json:"message"`
+}
+
+type TaskStatus struct {
+	ID             string            `json:"id"`
+	TaskDefinition string            `json:"task_definition"`
+	LastStatus     string            `json:"last_status"`
+	DesiredStatus  string            `json:"desired_status"`
+	HealthStatus   string            `json:"health_status"`
+	PrivateIPv4    string            `json:"private_ipv4,omitempty"`
+	StartedAt      *time.Time        `json:"started_at,omitempty"`
+	StoppedAt      *time.Time        `json:"stopped_at,omitempty"`
+	StopCode       string            `json:"stop_code,omitempty"`
+	StoppedReason  string            `json:"stopped_reason,omitempty"`
+	Containers     []ContainerStatus `json:"containers"`
+}
+
+type ContainerStatus struct {
+	Name         string `json:"name"`
+	ImageTag     string `json:"image_tag"`
+	LastStatus   string `json:"last_status"`
+	HealthStatus string `json:"health_status"`
+	ExitCode     *int32 `json:"exit_code,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// getTaskDefinitionName returns family:revision of the task definition ARN
+func getTaskDefinitionName(taskDefinitionArn string) string {
+	parts := strings.Split(taskDefinitionArn, "/")
+	return parts[len(parts)-1]
+}
+
+// describeServiceTasks returns the running and recently stopped tasks of the service. ECS keeps
+// stopped tasks for about an hour.
+func describeServiceTasks(ctx context.Context, cfg aws.Config, clusterName, serviceName string) ([]types.Task, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	tasksArns := make([]string, 0)
+	for _, desiredStatus := range []types.DesiredStatus{types.DesiredStatusRunning, types.DesiredStatusStopped} {
+		paginator := ecsLib.NewListTasksPaginator(ecsHandler, &ecsLib.ListTasksInput{
+			Cluster:       aws.String(clusterName),
+			ServiceName:   aws.String(serviceName),
+			DesiredStatus: desiredStatus,
+		})
+
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			tasksArns = append(tasksArns, page.TaskArns...)
+		}
+	}
+
+	tasks := make([]types.Task, 0)
+	for _, chunk := range utils.GetChunks(tasksArns, 100) {
+		detailedTasks, err := ecsHandler.DescribeTasks(ctx, &ecsLib.DescribeTasksInput{
+			Cluster: aws.String(clusterName),
+			Tasks:   chunk,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, detailedTasks.Tasks...)
+	}
+
+	return tasks, nil
+}
+
+// getContainerInstanceIPs returns the private IP of the ec2 instance behind each container instance
+func getContainerInstanceIPs(ctx context.Context, cfg aws.Config, clusterName string, containerInstanceArns []string) (map[string]string, error) {
+	ips := make(map[string]string)
+	if len(containerInstanceArns) == 0 {
+		return ips, nil
+	}
+
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	instanceIDs := make([]string, 0)
+	containerInstanceIDs := make(map[string]string)
+	for _, chunk := range utils.GetChunks(containerInstanceArns, 100) {
+		containerInstances, err := ecsHandler.DescribeContainerInstances(ctx, &ecsLib.DescribeContainerInstancesInput{
+			Cluster:            aws.String(clusterName),
+			ContainerInstances: chunk,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, containerInstance := range containerInstances.ContainerInstances {
+			instanceID := aws.ToString(containerInstance.Ec2InstanceId)
+			containerInstanceIDs[aws.ToString(containerInstance.ContainerInstanceArn)] = instanceID
+			instanceIDs = append(instanceIDs, instanceID)
+		}
+	}
+
+	instances, err := ec2.DescribeInstances(ctx, cfg, instanceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceIPs := make(map[string]string)
+	for _, instance := range *instances {
+		instanceIPs[instance.ID] = instance.PrivateIPv4
+	}
+
+	for containerInstanceArn, instanceID := range containerInstanceIDs {
+		ips[containerInstanceArn] = instanceIPs[instanceID]
+	}
+
+	return ips, nil
+}
+
+func newTaskStatus(task types.Task, containerInstanceIPs map[string]string) TaskStatus {
+	taskArnParts := strings.Split(aws.ToString(task.TaskArn), "/")
+
+	status := TaskStatus{
+		ID:             taskArnParts[len(taskArnParts)-1],
+		TaskDefinition: getTaskDefinitionName(aws.ToString(task.TaskDefinitionArn)),
+		LastStatus:     aws.ToString(task.LastStatus),
+		DesiredStatus:  aws.ToString(task.DesiredStatus),
+		HealthStatus:   string(task.HealthStatus),
+		PrivateIPv4:    containerInstanceIPs[aws.ToString(task.ContainerInstanceArn)],
+		StartedAt:      task.StartedAt,
+		StoppedAt:      task.StoppedAt,
+		StopCode:       string(task.StopCode),
+		StoppedReason:  aws.ToString(task.StoppedReason),
+		Containers:     make([]ContainerStatus, 0),
+	}
+
+	for _, container := range task.Containers {
+		// tasks in awsvpc mode have their own IP instead of the host's
+		for _, networkInterface := range container.NetworkInterfaces {
+			if networkInterface.PrivateIpv4Address != nil {
+				status.PrivateIPv4 = aws.ToString(networkInterface.PrivateIpv4Address)
+			}
+		}
+
+		status.Containers = append(status.Containers, ContainerStatus{
+			Name:         aws.ToString(container.Name),
+			ImageTag:     getImageTag(aws.ToString(container.Image)),
+			LastStatus:   aws.ToString(container.LastStatus),
+			HealthStatus: string(container.HealthStatus),
+			ExitCode:     container.ExitCode,
+			Reason:       aws.ToString(container.Reason),
+		})
+	}
+
+	return status
+}
+
+// DescribeServices returns the status of the services of the cluster matching serviceName with
+// the latest events of each service
+func DescribeServices(ctx context.Context, cfg aws.Config, clusterName, serviceName string, events int) ([]ServiceStatus, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	cluster := Cluster{Name: clusterName}
+	if err := cluster.GetServices(ctx, cfg, serviceName); err != nil {
+		return nil, err
+	}
+
+	serviceNames := make([]string, 0)
+	for _, service := range cluster.Services {
+		serviceNames = append(serviceNames, service.Name)
+	}
+
+	sort.Strings(serviceNames)
+
+	statuses := make([]ServiceStatus, 0)
+	for _, chunk := range utils.GetChunks(serviceNames, 10) {
+		servicesOutput, err := ecsHandler.DescribeServices(ctx, &ecsLib.DescribeServicesInput{
+			Cluster:  aws.String(clusterName),
+			Services: chunk,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, service := range servicesOutput.Services {
+			status := ServiceStatus{
+				Cluster:        clusterName,
+				Name:           aws.ToString(service.ServiceName),
+				Status:         aws.ToString(service.Status),
+				TaskDefinition: getTaskDefinitionName(aws.ToString(service.TaskDefinition)),
+				DesiredCount:   service.DesiredCount,
+				RunningCount:   service.RunningCount,
+				PendingCount:   service.PendingCount,
+				Deployments:    make([]DeploymentStatus, 0),
+				Events:         make([]ServiceEvent, 0),
+				Tasks:          make([]TaskStatus, 0),
+			}
+
+			for _, deployment := range service.Deployments {
+				status.Deployments = append(status.Deployments, DeploymentStatus{
+					ID:                 aws.ToString(deployment.Id),
+					Status:             aws.ToString(deployment.Status),
+					TaskDefinition:     getTaskDefinitionName(aws.ToString(deployment.TaskDefinition)),
+					RolloutState:       string(deployment.RolloutState),
+					RolloutStateReason: aws.ToString(deployment.RolloutStateReason),
+					DesiredCount:       deployment.DesiredCount,
+					RunningCount:       deployment.RunningCount,
+					PendingCount:       deployment.PendingCount,
+					FailedTasks:        deployment.FailedTasks,
+					CreatedAt:          deployment.CreatedAt,
+					UpdatedAt:          deployment.UpdatedAt,
+				})
+			}
+
+			// events are returned newest first
+			for i, event := range service.Events {
+				if i >= events {
+					break
+				}
+
+				status.Events = append(status.Events, ServiceEvent{
+					CreatedAt: event.CreatedAt,
+					Message:   aws.ToString(event.Message),
+				})
+			}
+
+			tasks, err := describeServiceTasks(ctx, cfg, clusterName, status.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			containerInstancesMap := make(map[string]bool)
+			for _, task := range tasks {
+				if task.ContainerInstanceArn != nil {
+					containerInstancesMap[aws.ToString(task.ContainerInstanceArn)] = true
+				}
+			}
+
+			containerInstanceArns := make([]string, 0)
+			for containerInstanceArn := range containerInstancesMap {
+				containerInstanceArns = append(containerInstanceArns, containerInstanceArn)
+			}
+
+			containerInstanceIPs, err := getContainerInstanceIPs(ctx, cfg, clusterName, containerInstanceArns)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, task := range tasks {
+				status.Tasks = append(status.Tasks, newTaskStatus(task, containerInstanceIPs))
+			}
+
+			// running tasks first, then the most recently stopped
+			sort.SliceStable(status.Tasks, func(i, j int) bool {
+				if status.Tasks[i].StoppedAt == nil || status.Tasks[j].StoppedAt == nil {
+					return status.Tasks[i].StoppedAt == nil && status.Tasks[j].StoppedAt != nil
+				}
+
+				return status.Tasks[i].StoppedAt.After(*status.Tasks[j].StoppedAt)
+			})
+
+			statuses = append(statuses, status)
+		}
+	}
+
+	return statuses, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func colorStatus(status string) string {
+	switch status {
+	case "RUNNING", "HEALTHY", "COMPLETED", "ACTIVE", "PRIMARY":
+		return logger.Green(status)
+	case "STOPPED", "UNHEALTHY", "FAILED", "DRAINING", "INACTIVE":
+		return logger.Red(status)
+	case "":
+		return "-"
+	default:
+		return logger.Yellow(status)
+	}
+}
+
+func (s *ServiceStatus) Print() {
+	fmt.Println(
+		logger.Bold(s.Name),
+		fmt.Sprintf("(%s)", s.Cluster),
+		colorStatus(s.Status),
+		logger.Italic(s.TaskDefinition),
+		fmt.Sprintf("desired %d, running %d, pending %d", s.DesiredCount, s.RunningCount, s.PendingCount),
+	)
+
+	// table cells are not colored as escape codes would break the alignment
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Println(logger.Underline("Deployments"))
+	fmt.Fprintln(w, "  STATUS\tTASK DEFINITION\tROLLOUT\tDESIRED\tRUNNING\tPENDING\tFAILED\tUPDATED\t")
+	for _, deployment := range s.Deployments {
+		fmt.Fprintf(
+			w,
+			"  %s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t\n",
+			deployment.Status,
+			deployment.TaskDefinition,
+			orDash(deployment.RolloutState),
+			deployment.DesiredCount,
+			deployment.RunningCount,
+			deployment.PendingCount,
+			deployment.FailedTasks,
+			formatTime(deployment.UpdatedAt),
+		)
+	}
+	w.Flush()
+
+	for _, deployment := range s.Deployments {
+		if deployment.RolloutStateReason != "" {
+			fmt.Println("  ", logger.Italic(deployment.TaskDefinition+": "+deployment.RolloutStateReason))
+		}
+	}
+
+	fmt.Println(logger.Underline("Tasks"))
+	fmt.Fprintln(w, "  TASK\tTASK DEFINITION\tSTATUS\tHEALTH\tIP\tCONTAINERS\tSTOP REASON\t")
+	for _, task := range s.Tasks {
+		containers := make([]string, 0)
+		for _, container := range task.Containers {
+			c := fmt.Sprintf("%s:%s %s", container.Name, container.ImageTag, container.LastStatus)
+			if container.HealthStatus != "" && container.HealthStatus != string(types.HealthStatusUnknown) {
+				c += " " + container.HealthStatus
+			}
+
+			if container.ExitCode != nil {
+				c += fmt.Sprintf(" exit %d", *container.ExitCode)
+			}
+
+			if container.Reason != "" {
+				c += fmt.Sprintf(" (%s)", container.Reason)
+			}
+
+			containers = append(containers, c)
+		}
+
+		fmt.Fprintf(
+			w,
+			"  %s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			task.ID,
+			task.TaskDefinition,
+			task.LastStatus,
+			orDash(task.HealthStatus),
+			orDash(task.PrivateIPv4),
+			strings.Join(containers, ", "),
+			orDash(task.StoppedReason),
+		)
+	}
+	w.Flush()
+
+	if len(s.Events) > 0 {
+		fmt.Println(logger.Underline("Events"))
+		for _, event := range s.Events {
+			fmt.Println(" ", logger.Italic(formatTime(event.CreatedAt)), event.Message)
+		}
+	}
+
+	fmt.Println()
+}
+
+func printServiceStatuses(statuses []ServiceStatus, asJSON bool) error {
+	if asJSON {
+		statusesBytes, err := json.MarshalIndent(statuses, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(statusesBytes))
+
+		return nil
+	}
+
+	for _, status := range statuses {
+		status.Print()
+	}
+
+	return nil
+}
@@ -19,20 +19,25 @@ type ContainerInstance struct {
 	Instance ec2.Instance
 }
 
-func Describe(ctx context.Context, cfg aws.Config, serviceName, clusterName string) error {
+// Describe prints the counts, deployments, tasks and latest events of the matching services of the matching clusters
+func Describe(ctx context.Context, cfg aws.Config, serviceName, clusterName string, events int, asJSON bool) error {
 	clusters, err := ListClusters(ctx, cfg, clusterName)
 	if err != nil {
 		return err
 	}
 
+	statuses := make([]ServiceStatus, 0)
 	for _, cluster := range *clusters {
-		c, err := DescribeByCluster(ctx, cfg, cluster.Name, serviceName)
-		if err == nil {
-			c.Print()
+		clusterStatuses, err := DescribeServices(ctx, cfg, cluster.Name, serviceName, events)
+		if err != nil {
+			logger.Error("Unable to describe cluster %s. Error: %s", logger.Underline(cluster.Name), err.Error())
+			continue
 		}
+
+		statuses = append(statuses, clusterStatuses...)
 	}
 
-	return nil
+	return printServiceStatuses(statuses, asJSON)
 }
 
 func DescribeByCluster(ctx context.Context, cfg aws.Config, clusterName, serviceName string) (*Cluster, error) {
@@ -230,6 +235,7 @@ func Revert(
 	cfg aws.Config,
 	cluster,
 	service,
+	containerName,
 	tagToRevertTo string,
 	revisionsToLookback int32,
 ) error {
@@ -251,9 +257,22 @@ func Revert(
 		for j, service := range cluster.Services {
 			service.GetTaskDefintions(ctx, cfg, revisionsToLookback)
 
-			for _, taskDefinition := range service.TaskDefinitions {
-				if strings.Contains(taskDefinition.Image, tagToRevertTo) {
+			if service.CurrentTaskDefinition == nil {
+				logger.Warn("Unable to get task definitions of %s/%s", logger.Italic(cluster.Name), logger.Italic(service.Name))
+				continue
+			}
+
+			serviceContainerName, err := service.CurrentTaskDefinition.ResolveContainer(service.Name, containerName)
+			if err != nil {
+				logger.Warn("Wont revert %s/%s: %s", logger.Italic(cluster.Name), logger.Italic(service.Name), err.Error())
+				continue
+			}
+
+			for k, taskDefinition := range service.TaskDefinitions {
+				image, ok := taskDefinition.GetImage(serviceContainerName)
+				if ok && getImageTag(image) == tagToRevertTo {
 					service.TaskDefinitionArn = taskDefinition.GetNameWithVersion()
+					service.RevertTaskDefinition = &service.TaskDefinitions[k]
 					service.CanRevert = true
 					break
 				}
@@ -308,6 +327,7 @@ func revertServices(
 					logger.Italic(service.OldTaskDefinitionArn),
 					logger.Bold(service.TaskDefinitionArn),
 				)
+				printImageDiff(service.CurrentTaskDefinition, service.RevertTaskDefinition)
 				continue
 			}
 
@@ -339,3 +359,33 @@ func revertServices(
 		}
 	}
 }
+
+// printImageDiff prints the image of every container of the current and target revisions
+func printImageDiff(current, target *TaskDefinition) {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, td := range []*TaskDefinition{current, target} {
+		for _, container := range td.Containers {
+			if !seen[container.Name] {
+				seen[container.Name] = true
+				names = append(names, container.Name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		currentImage, inCurrent := current.GetImage(name)
+		targetImage, inTarget := target.GetImage(name)
+
+		switch {
+		case !inTarget:
+			fmt.Println(logger.Red(fmt.Sprintf("    - %s: %s", name, currentImage)))
+		case !inCurrent:
+			fmt.Println(logger.Green(fmt.Sprintf("    + %s: %s", name, targetImage)))
+		case currentImage == targetImage:
+			fmt.Println(fmt.Sprintf("      %s: %s (unchanged)", name, currentImage))
+		default:
+			fmt.Println(fmt.Sprintf("    ~ %s: %s -> %s", name, logger.Italic(currentImage), logger.Bold(targetImage)))
+		}
+	}
+}
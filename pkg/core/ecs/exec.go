@@ -0,0 +1,226 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+const (
+	shellModeSSH  = "ssh"
+	shellModeExec = "exec"
+)
+
+// getShellMode returns the shell mode of the cluster from onyx config, defaulting to ssh
+func getShellMode(clusterName string) string {
+	if clusterConfig, ok := config.Config.ECSClusters[clusterName]; ok && clusterConfig.ShellMode != "" {
+		return clusterConfig.ShellMode
+	}
+
+	return shellModeSSH
+}
+
+// execTarget is a container of a running task reachable through ECS Exec
+type execTarget struct {
+	ClusterName          string
+	ServiceName          string
+	TaskArn              string
+	ContainerInstanceArn string
+	Container            string
+	RuntimeID            string
+}
+
+func (t *execTarget) TaskID() string {
+	parts := strings.Split(t.TaskArn, "/")
+	return parts[len(parts)-1]
+}
+
+func (t *execTarget) String() string {
+	return fmt.Sprintf("%s | %s | %s", t.ServiceName, t.TaskID(), t.Container)
+}
+
+// getExecTargets returns the container of every running task of the services matching serviceName.
+// The container is containerName if provided, else the one named after the service, else the only one of the task.
+func getExecTargets(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName, taskID string) ([]execTarget, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	cluster := Cluster{Name: clusterName}
+	if err := cluster.GetServices(ctx, cfg, serviceName); err != nil {
+		return nil, err
+	}
+
+	targets := make([]execTarget, 0)
+	for _, service := range cluster.Services {
+		tasksOutput, err := ecsHandler.ListTasks(ctx, &ecsLib.ListTasksInput{
+			Cluster:       aws.String(clusterName),
+			ServiceName:   aws.String(service.Name),
+			DesiredStatus: types.DesiredStatusRunning,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tasksOutput.TaskArns) == 0 {
+			continue
+		}
+
+		detailedTasks, err := ecsHandler.DescribeTasks(ctx, &ecsLib.DescribeTasksInput{
+			Cluster: aws.String(clusterName),
+			Tasks:   tasksOutput.TaskArns,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, task := range detailedTasks.Tasks {
+			target := execTarget{
+				ClusterName:          clusterName,
+				ServiceName:          service.Name,
+				TaskArn:              aws.ToString(task.TaskArn),
+				ContainerInstanceArn: aws.ToString(task.ContainerInstanceArn),
+			}
+
+			if taskID != "" && target.TaskID() != taskID && target.TaskArn != taskID {
+				continue
+			}
+
+			container, err := selectContainer(task.Containers, service.Name, containerName)
+			if err != nil {
+				return nil, fmt.Errorf("%s of %s: %s", target.TaskID(), service.Name, err.Error())
+			}
+
+			if container.RuntimeId == nil {
+				logger.Warn("Container %s of %s is not running, skipping", aws.ToString(container.Name), target.TaskID())
+				continue
+			}
+
+			target.Container = aws.ToString(container.Name)
+			target.RuntimeID = aws.ToString(container.RuntimeId)
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, nil
+}
+
+func selectContainer(containers []types.Container, serviceName, containerName string) (types.Container, error) {
+	names := make([]string, 0)
+	for _, container := range containers {
+		names = append(names, aws.ToString(container.Name))
+	}
+
+	if containerName != "" {
+		for _, container := range containers {
+			if aws.ToString(container.Name) == containerName {
+				return container, nil
+			}
+		}
+
+		return types.Container{}, fmt.Errorf("no container %s, available: %s", containerName, strings.Join(names, ", "))
+	}
+
+	for _, container := range containers {
+		if aws.ToString(container.Name) == serviceName {
+			return container, nil
+		}
+	}
+
+	if len(containers) == 1 {
+		return containers[0], nil
+	}
+
+	return types.Container{}, fmt.Errorf("multiple containers, choose one with --container: %s", strings.Join(names, ", "))
+}
+
+func selectExecTarget(targets []execTarget) (execTarget, error) {
+	if len(targets) == 1 {
+		return targets[0], nil
+	}
+
+	logger.Info("Select task to connect to")
+	for i, target := range targets {
+		fmt.Println(logger.Bold(i), ":", target.String())
+	}
+
+	choice := strings.TrimSpace(utils.GetUserInput("Enter Choice: "))
+
+	i, err := strconv.ParseInt(choice, 0, 32)
+	if err != nil || i < 0 || i >= int64(len(targets)) {
+		return execTarget{}, errors.New(logger.Bold("Invalid choice"))
+	}
+
+	return targets[i], nil
+}
+
+// startExecSession runs command in the target container through ECS Exec, attaching the terminal to
+// the SSM session with the session-manager-plugin the same way the AWS CLI does
+func startExecSession(ctx context.Context, cfg aws.Config, target execTarget, command string) error {
+	plugin, err := getExecSessionCommand(ctx, cfg, target, command)
+	if err != nil {
+		return err
+	}
+
+	plugin.Stdin = os.Stdin
+	plugin.Stdout = os.Stdout
+	plugin.Stderr = os.Stderr
+
+	return plugin.Run()
+}
+
+// getExecSessionCommand starts the ECS Exec session running command in the target container and
+// returns the session-manager-plugin command which attaches to it
+func getExecSessionCommand(ctx context.Context, cfg aws.Config, target execTarget, command string) (*exec.Cmd, error) {
+	pluginPath, err := exec.LookPath("session-manager-plugin")
+	if err != nil {
+		return nil, errors.New("session-manager-plugin not found. Install it to use ECS Exec: https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html")
+	}
+
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	output, err := ecsHandler.ExecuteCommand(ctx, &ecsLib.ExecuteCommandInput{
+		Cluster:     aws.String(target.ClusterName),
+		Task:        aws.String(target.TaskArn),
+		Container:   aws.String(target.Container),
+		Command:     aws.String(command),
+		Interactive: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sessionBytes, err := json.Marshal(output.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	targetBytes, err := json.Marshal(map[string]string{
+		"Target": fmt.Sprintf("ecs:%s_%s_%s", target.ClusterName, target.TaskID(), target.RuntimeID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	region := config.GetRegion()
+
+	return exec.Command(
+		pluginPath,
+		string(sessionBytes),
+		region,
+		"StartSession",
+		"",
+		string(targetBytes),
+		fmt.Sprintf("https://ecs.%s.amazonaws.com", region),
+	), nil
+}
@@ -0,0 +1,252 @@
+package ecs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/auth"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// ECS Exec sessions don't return the exit code of the command, so it is echoed after the output
+const exitCodeMarker = "__onyx_exit_code="
+
+var exitCodeRegex = regexp.MustCompile(exitCodeMarker + `(\d+)`)
+
+type commandResult struct {
+	Target   execTarget
+	Host     string
+	Output   string
+	ExitCode int
+	Err      error
+}
+
+// runSSHCommand runs command in the target container through docker exec on its host
+func runSSHCommand(host string, target execTarget, command string) commandResult {
+	result := commandResult{Target: target, Host: host, ExitCode: -1}
+	if host == "" {
+		result.Err = errors.New("no container instance, use shell_mode exec for fargate tasks")
+		return result
+	}
+
+	remoteCommand := fmt.Sprintf("docker exec %s sh -c %s", target.RuntimeID, utils.ShellQuote(command))
+
+	var output bytes.Buffer
+	cmd := exec.Command("sudo", "ssh", "-i", config.Config.PrivateKey, "-o", "StrictHostKeyChecking=no", "ec2-user@"+host, remoteCommand)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	result.Output = output.String()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		result.ExitCode = 0
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		result.Err = err
+	}
+
+	return result
+}
+
+// runExecCommand runs command in the target container through ECS Exec
+func runExecCommand(ctx context.Context, cfg aws.Config, target execTarget, command string) commandResult {
+	result := commandResult{Target: target, ExitCode: -1}
+
+	cmd, err := getExecSessionCommand(ctx, cfg, target, fmt.Sprintf("sh -c %s", utils.ShellQuote(command+"; echo "+exitCodeMarker+"$?")))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		result.Output = output.String()
+		result.Err = err
+		return result
+	}
+
+	// the session manager plugin prints its own banner around the output
+	out := strings.ReplaceAll(output.String(), "\r\n", "\n")
+	if i := strings.Index(out, "Starting session with SessionId:"); i >= 0 {
+		if j := strings.Index(out[i:], "\n"); j >= 0 {
+			out = out[i+j+1:]
+		}
+	}
+
+	if matches := exitCodeRegex.FindAllStringSubmatchIndex(out, -1); len(matches) > 0 {
+		match := matches[len(matches)-1]
+		result.ExitCode, _ = strconv.Atoi(out[match[2]:match[3]])
+		out = out[:match[0]]
+	} else {
+		result.Err = errors.New("unable to read the exit code of the command")
+	}
+
+	result.Output = out
+
+	return result
+}
+
+// ExecServiceCommand runs command in the container of every running task of the service, at most parallelism
+// at a time, and prints the output of each task followed by a summary of the exit codes
+func ExecServiceCommand(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName, command string, parallelism int) error {
+	if !strings.Contains(clusterName, config.Config.Environment) {
+		logger.Error("You are in %s environment but you are trying to access %s environment", logger.Underline(config.Config.Environment), clusterName)
+		return nil
+	}
+
+	if parallelism < 1 {
+		return errors.New("parallelism should be at least 1")
+	}
+
+	serviceName, err := resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	currUser, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	username := currUser.Username
+
+	isAuthorized, err := auth.CheckUserAccessForService(ctx, username, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		logger.Error("%s is not authorized to access %s", logger.Underline(username), logger.Bold(serviceName))
+		audit.Log(ctx, audit.Entry{
+			Command: "ecs/exec",
+			Target:  serviceName + "@" + clusterName,
+			Outcome: audit.OutcomeDenied,
+			Message: fmt.Sprintf("[ecs/exec] *%s* is not authorized to run `%s` on %s", utils.GetUser(), command, serviceName),
+		})
+		return nil
+	}
+
+	allTargets, err := getExecTargets(ctx, cfg, clusterName, serviceName, containerName, "")
+	if err != nil {
+		return err
+	}
+
+	// services are matched by substring, only the resolved service is targeted
+	targets := make([]execTarget, 0)
+	for _, target := range allTargets {
+		if target.ServiceName == serviceName {
+			targets = append(targets, target)
+		}
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no running task of %s found", logger.Underline(serviceName))
+	}
+
+	useExec := getShellMode(clusterName) == shellModeExec
+
+	hosts := make(map[string]string)
+	if !useExec {
+		containerInstanceArns := make([]string, 0)
+		for _, target := range targets {
+			if target.ContainerInstanceArn != "" {
+				containerInstanceArns = append(containerInstanceArns, target.ContainerInstanceArn)
+			}
+		}
+
+		hosts, err = getContainerInstanceIPs(ctx, cfg, clusterName, containerInstanceArns)
+		if err != nil {
+			return err
+		}
+
+		userUID := syscall.Getuid()
+		if err := syscall.Setuid(0); err != nil {
+			return err
+		}
+
+		defer syscall.Setuid(userUID)
+	}
+
+	log := fmt.Sprintf("[ecs/exec] *%s* ran `%s` in %d task(s) of %s", utils.GetUser(), command, len(targets), serviceName)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/exec", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeSuccess, Message: log})
+
+	logger.Info("Running %s in %d task(s) of %s", logger.Bold(command), len(targets), logger.Underline(serviceName))
+
+	results := make([]commandResult, len(targets))
+	semaphore := make(chan struct{}, parallelism)
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target execTarget) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			if useExec {
+				results[i] = runExecCommand(ctx, cfg, target, command)
+			} else {
+				results[i] = runSSHCommand(hosts[target.ContainerInstanceArn], target, command)
+			}
+		}(i, target)
+	}
+
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		fmt.Println(logger.Bold(result.Target.String()), logger.Italic(result.Host))
+		fmt.Println(strings.TrimRight(result.Output, "\n"))
+		fmt.Println()
+
+		if result.Err != nil || result.ExitCode != 0 {
+			failed++
+		}
+	}
+
+	logger.Info("Summary")
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			fmt.Println(result.Target.String(), ":", logger.Red(result.Err.Error()))
+		case result.ExitCode != 0:
+			fmt.Println(result.Target.String(), ":", logger.Red(fmt.Sprintf("exit %d", result.ExitCode)))
+		default:
+			fmt.Println(result.Target.String(), ":", logger.Green("exit 0"))
+		}
+	}
+
+	if failed > 0 {
+		log = fmt.Sprintf("[ecs/exec] `%s` by *%s* failed in %d of %d task(s) of %s", command, utils.GetUser(), failed, len(results), serviceName)
+		audit.Log(ctx, audit.Entry{Command: "ecs/exec", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeFailure, Message: log})
+
+		return fmt.Errorf("command failed in %d of %d task(s)", failed, len(results))
+	}
+
+	logger.Success("Command succeeded in %d task(s)", len(results))
+
+	return nil
+}
@@ -0,0 +1,408 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudwatchlogsLib "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+const (
+	logsModeSSH        = "ssh"
+	logsModeCloudWatch = "cloudwatch"
+)
+
+const (
+	logsPollInterval = 2 * time.Second
+	// streams are refreshed periodically to follow tasks started by deployments or scaling
+	logsStreamsRefreshInterval = 30 * time.Second
+	// events can reach CloudWatch out of order across streams, so every poll goes back this far
+	// behind the last event seen
+	logsLagWindow = 10 * time.Second
+)
+
+// getLogsMode returns the logs mode of the cluster from onyx config, defaulting to ssh
+func getLogsMode(clusterName string) string {
+	if clusterConfig, ok := config.Config.ECSClusters[clusterName]; ok && clusterConfig.LogsMode != "" {
+		return clusterConfig.LogsMode
+	}
+
+	return logsModeSSH
+}
+
+// logStream is the awslogs stream of a container of a running task
+type logStream struct {
+	Group     string
+	Region    string
+	Name      string
+	TaskID    string
+	Container string
+}
+
+// getServiceLogStreams reads the awslogs configuration of the service's task definition and returns
+// the streams of every running task, optionally limited to containerName
+func getServiceLogStreams(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName string) ([]logStream, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	servicesOutput, err := ecsHandler.DescribeServices(ctx, &ecsLib.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: []string{serviceName},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(servicesOutput.Services) == 0 {
+		return nil, fmt.Errorf("no service %s found", logger.Underline(serviceName))
+	}
+
+	tasksOutput, err := ecsHandler.ListTasks(ctx, &ecsLib.ListTasksInput{
+		Cluster:       aws.String(clusterName),
+		ServiceName:   aws.String(serviceName),
+		DesiredStatus: types.DesiredStatusRunning,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasksOutput.TaskArns) == 0 {
+		return nil, nil
+	}
+
+	detailedTasks, err := ecsHandler.DescribeTasks(ctx, &ecsLib.DescribeTasksInput{
+		Cluster: aws.String(clusterName),
+		Tasks:   tasksOutput.TaskArns,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// tasks of a service mid deployment can run different task definitions
+	containerDefinitions := make(map[string][]types.ContainerDefinition)
+	streams := make([]logStream, 0)
+
+	for _, task := range detailedTasks.Tasks {
+		taskDefinitionArn := aws.ToString(task.TaskDefinitionArn)
+		if _, ok := containerDefinitions[taskDefinitionArn]; !ok {
+			taskDefinitionOutput, err := ecsHandler.DescribeTaskDefinition(ctx, &ecsLib.DescribeTaskDefinitionInput{
+				TaskDefinition: task.TaskDefinitionArn,
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			containerDefinitions[taskDefinitionArn] = taskDefinitionOutput.TaskDefinition.ContainerDefinitions
+		}
+
+		taskArnParts := strings.Split(aws.ToString(task.TaskArn), "/")
+		taskID := taskArnParts[len(taskArnParts)-1]
+
+		for _, containerDefinition := range containerDefinitions[taskDefinitionArn] {
+			name := aws.ToString(containerDefinition.Name)
+			if containerName != "" && name != containerName {
+				continue
+			}
+
+			if stream, ok := getContainerLogStream(containerDefinition, taskID); ok {
+				streams = append(streams, stream)
+			}
+		}
+	}
+
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("no container of %s ships logs with awslogs", logger.Underline(serviceName))
+	}
+
+	return streams, nil
+}
+
+// getContainerLogStream returns the awslogs stream of the container in the task, if the container
+// ships its logs with awslogs and a stream prefix
+func getContainerLogStream(containerDefinition types.ContainerDefinition, taskID string) (logStream, bool) {
+	logConfiguration := containerDefinition.LogConfiguration
+	if logConfiguration == nil || logConfiguration.LogDriver != types.LogDriverAwslogs {
+		return logStream{}, false
+	}
+
+	group := logConfiguration.Options["awslogs-group"]
+	prefix := logConfiguration.Options["awslogs-stream-prefix"]
+	if group == "" || prefix == "" {
+		return logStream{}, false
+	}
+
+	region := logConfiguration.Options["awslogs-region"]
+	if region == "" {
+		region = config.GetRegion()
+	}
+
+	name := aws.ToString(containerDefinition.Name)
+
+	return logStream{
+		Group:     group,
+		Region:    region,
+		Name:      fmt.Sprintf("%s/%s/%s", prefix, name, taskID),
+		TaskID:    taskID,
+		Container: name,
+	}, true
+}
+
+type logEvent struct {
+	ID        string
+	Timestamp int64
+	Stream    logStream
+	Message   string
+}
+
+// fetchLogEvents returns the events of the streams since startTime, sorted by time
+func fetchLogEvents(ctx context.Context, cfg aws.Config, streams []logStream, startTime int64, filter string) ([]logEvent, error) {
+	type groupKey struct {
+		Group  string
+		Region string
+	}
+
+	streamsByGroup := make(map[groupKey]map[string]logStream)
+	for _, stream := range streams {
+		key := groupKey{Group: stream.Group, Region: stream.Region}
+		if _, ok := streamsByGroup[key]; !ok {
+			streamsByGroup[key] = make(map[string]logStream)
+		}
+
+		streamsByGroup[key][stream.Name] = stream
+	}
+
+	events := make([]logEvent, 0)
+	for key, groupStreams := range streamsByGroup {
+		regionCfg := cfg.Copy()
+		regionCfg.Region = key.Region
+		logsHandler := cloudwatchlogsLib.NewFromConfig(regionCfg)
+
+		names := make([]string, 0)
+		for name := range groupStreams {
+			names = append(names, name)
+		}
+
+		// FilterLogEvents accepts at most 100 stream names
+		for _, chunk := range utils.GetChunks(names, 100) {
+			input := &cloudwatchlogsLib.FilterLogEventsInput{
+				LogGroupName:   aws.String(key.Group),
+				LogStreamNames: chunk,
+				StartTime:      aws.Int64(startTime),
+			}
+
+			if filter != "" {
+				input.FilterPattern = aws.String(filter)
+			}
+
+			paginator := cloudwatchlogsLib.NewFilterLogEventsPaginator(logsHandler, input)
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, event := range page.Events {
+					events = append(events, logEvent{
+						ID:        aws.ToString(event.EventId),
+						Timestamp: aws.ToInt64(event.Timestamp),
+						Stream:    groupStreams[aws.ToString(event.LogStreamName)],
+						Message:   aws.ToString(event.Message),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+
+	return events, nil
+}
+
+// printLogEvent prints the event prefixed with its task, pretty printing JSON messages
+// as time, level and message followed by the remaining fields
+func printLogEvent(event logEvent, showContainer, raw bool) {
+	prefix := event.Stream.TaskID
+	if showContainer {
+		prefix += "/" + event.Stream.Container
+	}
+
+	timestamp := time.Unix(0, event.Timestamp*int64(time.Millisecond)).Format("15:04:05.000")
+	message := strings.TrimRight(event.Message, "\n")
+
+	var fields map[string]interface{}
+	if raw || !strings.HasPrefix(strings.TrimSpace(message), "{") || json.Unmarshal([]byte(message), &fields) != nil {
+		fmt.Println(logger.Cyan(prefix), timestamp, message)
+		return
+	}
+
+	level := popField(fields, "level", "severity", "lvl")
+	text := popField(fields, "msg", "message")
+	popField(fields, "time", "timestamp", "ts", "@timestamp")
+
+	switch strings.ToLower(level) {
+	case "error", "fatal", "panic", "critical":
+		level = logger.Red(strings.ToUpper(level))
+	case "warn", "warning":
+		level = logger.Yellow(strings.ToUpper(level))
+	case "":
+	default:
+		level = logger.Green(strings.ToUpper(level))
+	}
+
+	keys := make([]string, 0)
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	rest := make([]string, 0)
+	for _, key := range keys {
+		value, _ := json.Marshal(fields[key])
+		rest = append(rest, fmt.Sprintf("%s=%s", logger.Italic(key), string(value)))
+	}
+
+	fmt.Println(logger.Cyan(prefix), timestamp, level, logger.Bold(text), strings.Join(rest, " "))
+}
+
+// popField removes and returns the first of keys present in fields
+func popField(fields map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := fields[key]; ok {
+			delete(fields, key)
+			if s, ok := value.(string); ok {
+				return s
+			}
+
+			return strconv.Quote(fmt.Sprint(value))
+		}
+	}
+
+	return ""
+}
+
+// followCloudWatchLogs prints the last tailLogs events since the given duration across every
+// running task of the service and then follows them until interrupted
+func followCloudWatchLogs(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName string, tailLogs int32, since time.Duration, filter string, raw bool) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	serviceName, err := resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	streams, err := getServiceLogStreams(ctx, cfg, clusterName, serviceName, containerName)
+	if err != nil {
+		return err
+	}
+
+	if len(streams) == 0 {
+		return fmt.Errorf("no running task of %s found", logger.Underline(serviceName))
+	}
+
+	log := fmt.Sprintf("[ecs/tail-logs] *%s* tailed cloudwatch logs of %d task(s) for %s", utils.GetUser(), len(streams), serviceName)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/tail-logs", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeSuccess, Message: log})
+
+	logger.Info("Following %d log stream(s) of %s. Press Ctrl+C to stop.", len(streams), logger.Underline(serviceName))
+
+	showContainer := false
+	for _, stream := range streams {
+		if stream.Container != streams[0].Container {
+			showContainer = true
+			break
+		}
+	}
+
+	startTime := time.Now().Add(-since).UnixNano() / int64(time.Millisecond)
+	events, err := fetchLogEvents(ctx, cfg, streams, startTime, filter)
+	if err != nil {
+		return err
+	}
+
+	// the next poll starts a lag window behind the last timestamp seen, so late events aren't missed,
+	// and the events fetched again are skipped by their ID
+	lastTimestamp := startTime
+	seen := make(map[string]int64)
+	printNew := func(events []logEvent, skip int) {
+		for i, event := range events {
+			if _, ok := seen[event.ID]; ok {
+				continue
+			}
+
+			if event.Timestamp > lastTimestamp {
+				lastTimestamp = event.Timestamp
+			}
+
+			seen[event.ID] = event.Timestamp
+			if i >= skip {
+				printLogEvent(event, showContainer, raw)
+			}
+		}
+
+		if windowStart := lastTimestamp - logsLagWindow.Milliseconds(); windowStart > startTime {
+			startTime = windowStart
+		}
+
+		for id, timestamp := range seen {
+			if timestamp < startTime {
+				delete(seen, id)
+			}
+		}
+	}
+
+	skip := 0
+	if tailLogs > 0 && len(events) > int(tailLogs) {
+		skip = len(events) - int(tailLogs)
+	}
+
+	printNew(events, skip)
+
+	lastRefresh := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Success("Exiting safely")
+			return nil
+		case <-time.After(logsPollInterval):
+		}
+
+		if time.Since(lastRefresh) > logsStreamsRefreshInterval {
+			if refreshed, err := getServiceLogStreams(ctx, cfg, clusterName, serviceName, containerName); err == nil && len(refreshed) > 0 {
+				streams = refreshed
+			}
+
+			lastRefresh = time.Now()
+		}
+
+		events, err := fetchLogEvents(ctx, cfg, streams, startTime, filter)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				logger.Success("Exiting safely")
+				return nil
+			}
+
+			return err
+		}
+
+		printNew(events, 0)
+	}
+}
@@ -0,0 +1,163 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/auth"
+	"github.com/mudrex/onyx/pkg/config"
+	sshPkg "github.com/mudrex/onyx/pkg/core/ssh"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// parsePortMapping parses <local-port>:<container-port> or <port> for the same port on both sides
+func parsePortMapping(mapping string) (int, int32, error) {
+	parts := strings.Split(mapping, ":")
+	if len(parts) > 2 {
+		return 0, 0, fmt.Errorf("invalid port mapping %s, expected <local-port>:<container-port>", mapping)
+	}
+
+	containerPort, err := strconv.ParseInt(parts[len(parts)-1], 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %s", parts[len(parts)-1])
+	}
+
+	localPort, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %s", parts[0])
+	}
+
+	return localPort, int32(containerPort), nil
+}
+
+// getContainerAddress returns the address the container port is reachable at from the task's host, the task IP
+// for tasks in awsvpc mode, else the host port it is bound to
+func getContainerAddress(ctx context.Context, cfg aws.Config, target execTarget, containerPort int32) (string, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	detailedTasks, err := ecsHandler.DescribeTasks(ctx, &ecsLib.DescribeTasksInput{
+		Cluster: aws.String(target.ClusterName),
+		Tasks:   []string{target.TaskArn},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, task := range detailedTasks.Tasks {
+		for _, container := range task.Containers {
+			if aws.ToString(container.Name) != target.Container {
+				continue
+			}
+
+			for _, networkInterface := range container.NetworkInterfaces {
+				if networkInterface.PrivateIpv4Address != nil {
+					return fmt.Sprintf("%s:%d", aws.ToString(networkInterface.PrivateIpv4Address), containerPort), nil
+				}
+			}
+
+			for _, networkBinding := range container.NetworkBindings {
+				if aws.ToInt32(networkBinding.ContainerPort) == containerPort {
+					return fmt.Sprintf("127.0.0.1:%d", aws.ToInt32(networkBinding.HostPort)), nil
+				}
+			}
+
+			return "", fmt.Errorf("port %d of %s is not bound to its host", containerPort, target.Container)
+		}
+	}
+
+	return "", fmt.Errorf("task %s not found", target.TaskID())
+}
+
+// PortForward forwards the local port to the container port of a running task of the service through the
+// task's host, until interrupted or idle for idleTimeout
+func PortForward(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName, taskID, mapping string, idleTimeout time.Duration) error {
+	if !strings.Contains(clusterName, config.Config.Environment) {
+		logger.Error("You are in %s environment but you are trying to access %s environment", logger.Underline(config.Config.Environment), clusterName)
+		return nil
+	}
+
+	localPort, containerPort, err := parsePortMapping(mapping)
+	if err != nil {
+		return err
+	}
+
+	serviceName, err = resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	currUser, err := user.Current()
+	if err != nil {
+		return err
+	}
+
+	username := currUser.Username
+
+	isAuthorized, err := auth.CheckUserAccessForService(ctx, username, serviceName)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		log := fmt.Sprintf("[ecs/port-forward] *%s* is not authorized to forward port %d of %s", utils.GetUser(), containerPort, serviceName)
+		audit.Log(ctx, audit.Entry{Command: "ecs/port-forward", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeDenied, Message: log})
+
+		logger.Error("%s is not authorized to access %s", logger.Underline(username), logger.Bold(serviceName))
+		return nil
+	}
+
+	allTargets, err := getExecTargets(ctx, cfg, clusterName, serviceName, containerName, taskID)
+	if err != nil {
+		return err
+	}
+
+	targets := make([]execTarget, 0)
+	for _, target := range allTargets {
+		if target.ServiceName == serviceName {
+			targets = append(targets, target)
+		}
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no running task of %s found", logger.Underline(serviceName))
+	}
+
+	target, err := selectExecTarget(targets)
+	if err != nil {
+		return err
+	}
+
+	if target.ContainerInstanceArn == "" {
+		return fmt.Errorf("task %s has no container instance to tunnel through", target.TaskID())
+	}
+
+	hosts, err := getContainerInstanceIPs(ctx, cfg, clusterName, []string{target.ContainerInstanceArn})
+	if err != nil {
+		return err
+	}
+
+	host := hosts[target.ContainerInstanceArn]
+	if host == "" {
+		return fmt.Errorf("unable to find the host of task %s", target.TaskID())
+	}
+
+	remoteAddress, err := getContainerAddress(ctx, cfg, target, containerPort)
+	if err != nil {
+		return err
+	}
+
+	log := fmt.Sprintf("[ecs/port-forward] *%s* forwarded port %d of _%s_ in task %s of %s", utils.GetUser(), containerPort, target.Container, target.TaskID(), serviceName)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/port-forward", Target: serviceName + "@" + target.TaskArn, Outcome: audit.OutcomeSuccess, Message: log})
+
+	return sshPkg.Forward(ctx, "ec2-user@"+host, localPort, remoteAddress, idleTimeout)
+}
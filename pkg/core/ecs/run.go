@@ -0,0 +1,270 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/auth"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+const runPollInterval = 5 * time.Second
+
+// RunServiceTask launches a standalone task with the service's task definition, network configuration and
+// capacity provider, with the command of the container overridden, and streams its logs until it stops.
+// It returns the exit code of the container.
+func RunServiceTask(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName string, command []string) (int, error) {
+	if !strings.Contains(clusterName, config.Config.Environment) {
+		return 0, fmt.Errorf("you are in %s environment but you are trying to access %s environment", config.Config.Environment, clusterName)
+	}
+
+	serviceName, err := resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return 0, err
+	}
+
+	currUser, err := user.Current()
+	if err != nil {
+		return 0, err
+	}
+
+	username := currUser.Username
+	fullCommand := strings.Join(command, " ")
+
+	isAuthorized, err := auth.CheckUserAccessForService(ctx, username, serviceName)
+	if err != nil {
+		return 0, err
+	}
+
+	if !isAuthorized {
+		audit.Log(ctx, audit.Entry{
+			Command: "ecs/run",
+			Target:  serviceName + "@" + clusterName,
+			Outcome: audit.OutcomeDenied,
+			Message: fmt.Sprintf("[ecs/run] *%s* is not authorized to run `%s` for %s", utils.GetUser(), fullCommand, serviceName),
+		})
+
+		return 0, fmt.Errorf("%s is not authorized to access %s", username, serviceName)
+	}
+
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	servicesOutput, err := ecsHandler.DescribeServices(ctx, &ecsLib.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: []string{serviceName},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(servicesOutput.Services) == 0 {
+		return 0, fmt.Errorf("no service %s found", logger.Underline(serviceName))
+	}
+
+	service := servicesOutput.Services[0]
+
+	taskDefinitionOutput, err := ecsHandler.DescribeTaskDefinition(ctx, &ecsLib.DescribeTaskDefinitionInput{
+		TaskDefinition: service.TaskDefinition,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	taskDefinition := taskDefinitionOutput.TaskDefinition
+
+	current := newTaskDefinition(aws.ToString(taskDefinition.Family), taskDefinition)
+	containerName, err = current.ResolveContainer(serviceName, containerName)
+	if err != nil {
+		return 0, err
+	}
+
+	// StartedBy is limited to 36 characters
+	startedBy := "onyx/" + username
+	if len(startedBy) > 36 {
+		startedBy = startedBy[:36]
+	}
+
+	input := &ecsLib.RunTaskInput{
+		Cluster:              aws.String(clusterName),
+		TaskDefinition:       taskDefinition.TaskDefinitionArn,
+		Count:                aws.Int32(1),
+		NetworkConfiguration: service.NetworkConfiguration,
+		PlacementConstraints: service.PlacementConstraints,
+		PlacementStrategy:    service.PlacementStrategy,
+		PlatformVersion:      service.PlatformVersion,
+		EnableECSManagedTags: service.EnableECSManagedTags,
+		StartedBy:            aws.String(startedBy),
+		Overrides: &types.TaskOverride{
+			ContainerOverrides: []types.ContainerOverride{
+				{
+					Name:    aws.String(containerName),
+					Command: command,
+				},
+			},
+		},
+	}
+
+	// the capacity provider strategy and the launch type can't be set together
+	if len(service.CapacityProviderStrategy) > 0 {
+		input.CapacityProviderStrategy = service.CapacityProviderStrategy
+	} else {
+		input.LaunchType = service.LaunchType
+	}
+
+	runOutput, err := ecsHandler.RunTask(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(runOutput.Tasks) == 0 {
+		reasons := make([]string, 0)
+		for _, failure := range runOutput.Failures {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", aws.ToString(failure.Arn), aws.ToString(failure.Reason)))
+		}
+
+		return 0, fmt.Errorf("unable to run task: %s", strings.Join(reasons, ", "))
+	}
+
+	taskArn := aws.ToString(runOutput.Tasks[0].TaskArn)
+	taskArnParts := strings.Split(taskArn, "/")
+	taskID := taskArnParts[len(taskArnParts)-1]
+
+	log := fmt.Sprintf("[ecs/run] *%s* ran `%s` in task %s of %s:%d for %s", utils.GetUser(), fullCommand, taskID, aws.ToString(taskDefinition.Family), taskDefinition.Revision, serviceName)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/run", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeSuccess, Message: log})
+
+	logger.Info("Started task %s running %s in %s", logger.Bold(taskID), logger.Italic(fullCommand), logger.Underline(containerName))
+
+	var stream *logStream
+	for _, containerDefinition := range taskDefinition.ContainerDefinitions {
+		if aws.ToString(containerDefinition.Name) == containerName {
+			if s, ok := getContainerLogStream(containerDefinition, taskID); ok {
+				stream = &s
+			}
+		}
+	}
+
+	if stream == nil {
+		logger.Warn("%s doesn't ship logs with awslogs, waiting for the task to stop", logger.Underline(containerName))
+	}
+
+	task, err := waitForTask(ctx, cfg, clusterName, taskArn, stream)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, container := range task.Containers {
+		if aws.ToString(container.Name) != containerName {
+			continue
+		}
+
+		if container.ExitCode == nil {
+			return 0, fmt.Errorf("task %s stopped without an exit code: %s %s", taskID, aws.ToString(task.StoppedReason), aws.ToString(container.Reason))
+		}
+
+		exitCode := int(*container.ExitCode)
+		if exitCode != 0 {
+			log = fmt.Sprintf("[ecs/run] `%s` by *%s* in task %s of %s exited with %d", fullCommand, utils.GetUser(), taskID, serviceName, exitCode)
+			audit.Log(ctx, audit.Entry{Command: "ecs/run", Target: serviceName + "@" + clusterName, Outcome: audit.OutcomeFailure, Message: log})
+			logger.Error("Task %s exited with %d", taskID, exitCode)
+		} else {
+			logger.Success("Task %s exited with 0", taskID)
+		}
+
+		return exitCode, nil
+	}
+
+	return 0, fmt.Errorf("container %s not found in task %s", containerName, taskID)
+}
+
+// waitForTask prints the log events of the stream, if any, until the task stops and returns the stopped task.
+// It stops waiting when interrupted, the task keeps running.
+func waitForTask(ctx context.Context, cfg aws.Config, clusterName, taskArn string, stream *logStream) (types.Task, error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	startTime := time.Now().Add(-time.Minute).UnixNano() / int64(time.Millisecond)
+	seen := make(map[string]bool)
+
+	printLogs := func() {
+		if stream == nil {
+			return
+		}
+
+		// the stream doesn't exist until the container starts
+		events, err := fetchLogEvents(ctx, cfg, []logStream{*stream}, startTime, "")
+		if err != nil {
+			return
+		}
+
+		for _, event := range events {
+			if seen[event.ID] {
+				continue
+			}
+
+			seen[event.ID] = true
+			if event.Timestamp > startTime {
+				startTime = event.Timestamp
+			}
+
+			printLogEvent(event, false, false)
+		}
+	}
+
+	lastStatus := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return types.Task{}, fmt.Errorf("stopped waiting for task %s, it is still running", taskArn)
+		case <-time.After(runPollInterval):
+		}
+
+		tasksOutput, err := ecsHandler.DescribeTasks(ctx, &ecsLib.DescribeTasksInput{
+			Cluster: aws.String(clusterName),
+			Tasks:   []string{taskArn},
+		})
+		if err != nil {
+			return types.Task{}, err
+		}
+
+		if len(tasksOutput.Tasks) == 0 {
+			return types.Task{}, errors.New("task not found")
+		}
+
+		task := tasksOutput.Tasks[0]
+		if status := aws.ToString(task.LastStatus); status != lastStatus {
+			logger.Info("Task is %s", logger.Bold(status))
+			lastStatus = status
+		}
+
+		printLogs()
+
+		if aws.ToString(task.LastStatus) == "STOPPED" {
+			// logs can reach CloudWatch after the task stops
+			select {
+			case <-ctx.Done():
+				return task, nil
+			case <-time.After(runPollInterval):
+			}
+
+			printLogs()
+
+			return task, nil
+		}
+	}
+}
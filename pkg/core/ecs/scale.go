@@ -3,14 +3,24 @@ package ecs
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/mudrex/onyx/pkg/audit"
 	"github.com/mudrex/onyx/pkg/config"
 	"github.com/mudrex/onyx/pkg/filesystem"
 	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
 )
 
 // ServiceLimits defines the service limits
@@ -44,68 +54,328 @@ func populateScaleUpDownMap() error {
 	return nil
 }
 
-func Scale(ctx context.Context, cfg aws.Config, action string) error {
-	err := populateScaleUpDownMap()
+// ServiceCounts are the counts of a service scale sets
+type ServiceCounts struct {
+	ClusterName  string `json:"cluster"`
+	Name         string `json:"service"`
+	DesiredCount int32  `json:"desired_count"`
+	// Scalable is false for services without an autoscaling target, min and max are only set for scalable ones
+	Scalable bool  `json:"scalable"`
+	MinCount int32 `json:"min_count"`
+	MaxCount int32 `json:"max_count"`
+}
+
+// ScaleSnapshot holds the counts of the services before a scale
+type ScaleSnapshot struct {
+	Action    string          `json:"action"`
+	CreatedAt time.Time       `json:"created_at"`
+	CreatedBy string          `json:"created_by"`
+	Services  []ServiceCounts `json:"services"`
+}
+
+type scaleResult struct {
+	From ServiceCounts
+	To   ServiceCounts
+	Err  error
+}
+
+func getCountsResourceID(counts ServiceCounts) string {
+	return "service/" + counts.ClusterName + "/" + counts.Name
+}
+
+// getServiceCounts returns the current desired count and autoscaling min and max of the service
+func getServiceCounts(ctx context.Context, cfg aws.Config, clusterName, serviceName string) (ServiceCounts, error) {
+	ecsHandler := ecs.NewFromConfig(cfg)
+	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
+
+	counts := ServiceCounts{ClusterName: clusterName, Name: serviceName}
+
+	output, err := ecsHandler.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: []string{serviceName},
+	})
 	if err != nil {
-		return err
+		return counts, err
 	}
 
-	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
-	ecsHandler := ecs.NewFromConfig(cfg)
+	if len(output.Services) == 0 {
+		return counts, fmt.Errorf("no service %s found in %s", serviceName, clusterName)
+	}
+
+	counts.DesiredCount = output.Services[0].DesiredCount
+
+	targets, err := autoscalingHandler.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+		ServiceNamespace:  types.ServiceNamespaceEcs,
+		ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+		ResourceIds:       []string{getCountsResourceID(counts)},
+	})
+	if err != nil {
+		return counts, err
+	}
+
+	if len(targets.ScalableTargets) > 0 {
+		counts.Scalable = true
+		counts.MinCount = aws.ToInt32(targets.ScalableTargets[0].MinCapacity)
+		counts.MaxCount = aws.ToInt32(targets.ScalableTargets[0].MaxCapacity)
+	}
+
+	return counts, nil
+}
 
+// getScaleTargets returns the counts of ecs_scale_up_config for the action, sorted by cluster and service
+func getScaleTargets(action string) ([]ServiceCounts, error) {
+	if err := populateScaleUpDownMap(); err != nil {
+		return nil, err
+	}
+
+	targets := make([]ServiceCounts, 0)
 	for cluster, service := range scaleUpDownMap {
 		for name, limits := range service {
 			minCount := limits.ScaleUpMinCount
-			desiredCount := limits.ScaleUpMinCount
 			if action == "down" {
 				minCount = limits.MinCount
-				desiredCount = limits.MinCount
 			}
 
-			output, err := ecsHandler.DescribeServices(ctx, &ecs.DescribeServicesInput{
-				Cluster:  aws.String(cluster),
-				Services: []string{name},
+			targets = append(targets, ServiceCounts{
+				ClusterName:  cluster,
+				Name:         name,
+				DesiredCount: minCount,
+				Scalable:     true,
+				MinCount:     minCount,
+				MaxCount:     limits.MaxCount,
 			})
-			if err != nil {
-				logger.Error("Unable to describe service %s in %s", logger.Red(logger.Underline(name)), cluster)
-				continue
-			}
+		}
+	}
 
-			if len(output.Services) == 0 {
-				logger.Error("No service %s found in %s", logger.Red(logger.Underline(name)), cluster)
-				continue
-			}
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].ClusterName != targets[j].ClusterName {
+			return targets[i].ClusterName < targets[j].ClusterName
+		}
+
+		return targets[i].Name < targets[j].Name
+	})
+
+	return targets, nil
+}
+
+// applyServiceCounts sets the autoscaling target, for scalable counts, and then the desired count of the service.
+// A target the service has now but not in counts, registered by an earlier scale, is deregistered so that its min
+// does not scale the service back up.
+func applyServiceCounts(ctx context.Context, cfg aws.Config, current, counts ServiceCounts) error {
+	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
+	ecsHandler := ecs.NewFromConfig(cfg)
+
+	if !counts.Scalable && current.Scalable {
+		_, err := autoscalingHandler.DeregisterScalableTarget(ctx, &applicationautoscaling.DeregisterScalableTargetInput{
+			ResourceId:        aws.String(getCountsResourceID(counts)),
+			ServiceNamespace:  types.ServiceNamespaceEcs,
+			ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+		})
+		if err != nil {
+			return fmt.Errorf("autoscaling error: %s", err.Error())
+		}
+	}
+
+	if counts.Scalable {
+		_, err := autoscalingHandler.RegisterScalableTarget(
+			ctx,
+			&applicationautoscaling.RegisterScalableTargetInput{
+				ResourceId:        aws.String(getCountsResourceID(counts)),
+				ServiceNamespace:  types.ServiceNamespaceEcs,
+				MinCapacity:       aws.Int32(counts.MinCount),
+				MaxCapacity:       aws.Int32(counts.MaxCount),
+				ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("autoscaling error: %s", err.Error())
+		}
+	}
+
+	_, err := ecsHandler.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster:      aws.String(counts.ClusterName),
+		Service:      aws.String(counts.Name),
+		DesiredCount: aws.Int32(counts.DesiredCount),
+	})
+
+	return err
+}
+
+func formatCountChange(from, to int32, fromScalable, toScalable bool) string {
+	switch {
+	case !fromScalable && !toScalable:
+		return "-"
+	case !toScalable:
+		return fmt.Sprintf("%d -> -", from)
+	case !fromScalable:
+		return fmt.Sprintf("- -> %d", to)
+	}
+
+	if from == to {
+		return fmt.Sprint(to)
+	}
 
-			originalDesiredCount := output.Services[0].DesiredCount
-
-			_, err = autoscalingHandler.RegisterScalableTarget(
-				ctx,
-				&applicationautoscaling.RegisterScalableTargetInput{
-					ResourceId:        aws.String("service/" + cluster + "/" + name),
-					ServiceNamespace:  types.ServiceNamespaceEcs,
-					MinCapacity:       aws.Int32(minCount),
-					MaxCapacity:       aws.Int32(limits.MaxCount),
-					ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
-				},
-			)
-			if err != nil {
-				logger.Error("%s (%d) -> %s (%s) | autoscaling error: %s", logger.Bold(action), desiredCount, logger.Red(logger.Underline(name)), cluster, err.Error())
-				continue
+	return fmt.Sprintf("%d -> %d", from, to)
+}
+
+// printScalePlan prints the current and target counts of every service
+func printScalePlan(action string, results []scaleResult) {
+	logger.Info("Planned scale %s changes", logger.Bold(action))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tCLUSTER\tMIN\tMAX\tDESIRED\t")
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(w, "%s\t%s\t%s\t\t\t\n", result.To.Name, result.To.ClusterName, result.Err.Error())
+			continue
+		}
+
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t\n",
+			result.To.Name,
+			result.To.ClusterName,
+			formatCountChange(result.From.MinCount, result.To.MinCount, result.From.Scalable, result.To.Scalable),
+			formatCountChange(result.From.MaxCount, result.To.MaxCount, result.From.Scalable, result.To.Scalable),
+			formatCountChange(result.From.DesiredCount, result.To.DesiredCount, true, true),
+		)
+	}
+
+	w.Flush()
+}
+
+// Scale sets the services of ecs_scale_up_config to their scale up or down counts, or on restore back to the
+// counts saved before the last scale, at most parallelism services at a time. With dryRun only the current and
+// target counts are printed. With skipChoice a snapshot which has not been restored is overwritten without asking.
+func Scale(ctx context.Context, cfg aws.Config, action string, dryRun, skipChoice bool, parallelism int) error {
+	if parallelism < 1 {
+		return errors.New("parallelism should be at least 1")
+	}
+
+	var targets []ServiceCounts
+	switch action {
+	case "up", "down":
+		var err error
+		if targets, err = getScaleTargets(action); err != nil {
+			return err
+		}
+	case "restore":
+		snapshot, err := loadScaleSnapshot(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		if snapshot == nil {
+			return fmt.Errorf("no snapshot of a previous scale found at %s, nothing to restore", getScaleSnapshotLocation())
+		}
+
+		logger.Info("Restoring counts from before scale %s by %s at %s", logger.Bold(snapshot.Action), snapshot.CreatedBy, snapshot.CreatedAt.Format(time.RFC3339))
+		targets = snapshot.Services
+	default:
+		return fmt.Errorf("invalid action %s, expected up, down or restore", action)
+	}
+
+	results := make([]scaleResult, len(targets))
+	for i, target := range targets {
+		results[i].To = target
+		results[i].From, results[i].Err = getServiceCounts(ctx, cfg, target.ClusterName, target.Name)
+	}
+
+	printScalePlan(action, results)
+
+	if dryRun {
+		return nil
+	}
+
+	// the counts from before this run are saved first so that a run dying halfway can be restored
+	if action != "restore" {
+		// a rerun of a run which died halfway would overwrite the snapshot with the half scaled counts
+		previous, err := loadScaleSnapshot(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		if previous != nil {
+			logger.Warn("Snapshot from before scale %s by %s at %s has not been restored", logger.Bold(previous.Action), previous.CreatedBy, previous.CreatedAt.Format(time.RFC3339))
+
+			if !skipChoice {
+				logger.Warn("Run %s to undo that scale, or overwrite the snapshot with the current counts", logger.Bold("onyx ecs scale restore"))
+				if logger.InfoScan("Choose y/n: ") != "y" {
+					logger.Success("Nothing to do")
+					return nil
+				}
 			}
+		}
 
-			_, err = ecsHandler.UpdateService(ctx, &ecs.UpdateServiceInput{
-				Cluster:      aws.String(cluster),
-				Service:      aws.String(name),
-				DesiredCount: aws.Int32(desiredCount),
-			})
-			if err != nil {
-				logger.Error("%s (%d) -> %s (%s) | Error: %s", logger.Bold(action), desiredCount, logger.Red(logger.Underline(name)), cluster, err.Error())
-			} else {
-				logger.Success("Scaled %s %s from %d -> %d", action, logger.Red(logger.Underline(name)), originalDesiredCount, desiredCount)
+		snapshot := &ScaleSnapshot{
+			Action:    action,
+			CreatedAt: time.Now().UTC(),
+			CreatedBy: utils.GetUser(),
+			Services:  make([]ServiceCounts, 0),
+		}
+
+		for _, result := range results {
+			if result.Err == nil {
+				snapshot.Services = append(snapshot.Services, result.From)
 			}
 		}
+
+		if err := saveScaleSnapshot(ctx, cfg, snapshot); err != nil {
+			return fmt.Errorf("unable to save the scale snapshot, nothing was changed: %s", err.Error())
+		}
+	}
+
+	semaphore := make(chan struct{}, parallelism)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *scaleResult) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			result.Err = applyServiceCounts(ctx, cfg, result.From, result.To)
+		}(&results[i])
 	}
 
+	wg.Wait()
+
+	failed := 0
+	logger.Info("Summary")
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("%s (%s) : %s\n", result.To.Name, result.To.ClusterName, logger.Red(result.Err.Error()))
+			continue
+		}
+
+		fmt.Printf("%s (%s) : %s\n", result.To.Name, result.To.ClusterName, logger.Green(fmt.Sprintf("desired %d -> %d", result.From.DesiredCount, result.To.DesiredCount)))
+	}
+
+	if failed > 0 {
+		log := fmt.Sprintf(":bangbang: [ecs/scale] *%s* ran scale %s, %d of %d service(s) failed", utils.GetUser(), action, failed, len(results))
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "ecs/scale", Target: action, Outcome: audit.OutcomeFailure, Message: log})
+
+		return fmt.Errorf("scale %s failed for %d of %d service(s)", action, failed, len(results))
+	}
+
+	if action == "restore" {
+		if err := deleteScaleSnapshot(ctx, cfg); err != nil {
+			logger.Warn("Unable to delete the scale snapshot: %s", err.Error())
+		}
+	}
+
+	log := fmt.Sprintf("[ecs/scale] *%s* ran scale %s for %d service(s)", utils.GetUser(), action, len(results))
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/scale", Target: action, Outcome: audit.OutcomeSuccess, Message: log})
+
 	logger.Success("Scale %s completed.", logger.Underline(action))
 
 	return nil
@@ -0,0 +1,125 @@
+package ecs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3Lib "github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/filesystem"
+)
+
+// getScaleSnapshotLocation returns the S3 key of the scale snapshot if sandstorm_state_bucket is set, so that
+// every operator restores the same snapshot, else the local file relative to the home directory
+func getScaleSnapshotLocation() string {
+	if config.Config.SandstormStateBucket != "" {
+		return "onyx/ecs/scale/snapshot.json"
+	}
+
+	return ".onyx-ecs-scale.json"
+}
+
+// loadScaleSnapshot returns the counts saved before the last scale, nil if there is none
+func loadScaleSnapshot(ctx context.Context, cfg aws.Config) (*ScaleSnapshot, error) {
+	var data []byte
+
+	if config.Config.SandstormStateBucket != "" {
+		s3Handler := s3Lib.NewFromConfig(cfg)
+
+		output, err := s3Handler.GetObject(ctx, &s3Lib.GetObjectInput{
+			Bucket: aws.String(config.Config.SandstormStateBucket),
+			Key:    aws.String(getScaleSnapshotLocation()),
+		})
+		if err != nil {
+			var noSuchKey *s3Types.NoSuchKey
+			if errors.As(err, &noSuchKey) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+		defer output.Body.Close()
+
+		data, err = io.ReadAll(output.Body)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !filesystem.FileExists(getScaleSnapshotLocation()) {
+			return nil, nil
+		}
+
+		fileData, err := filesystem.ReadFile(getScaleSnapshotLocation())
+		if err != nil {
+			return nil, err
+		}
+
+		data = []byte(fileData)
+	}
+
+	var snapshot ScaleSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("unable to parse the scale snapshot: %s", err.Error())
+	}
+
+	return &snapshot, nil
+}
+
+func saveScaleSnapshot(ctx context.Context, cfg aws.Config, snapshot *ScaleSnapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if config.Config.SandstormStateBucket == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+
+		// the counts are only for the user who scaled, a file left by an older version keeps its mode on write
+		filename := fmt.Sprintf("%s/%s", home, getScaleSnapshotLocation())
+		if err := os.WriteFile(filename, data, 0600); err != nil {
+			return err
+		}
+
+		return os.Chmod(filename, 0600)
+	}
+
+	s3Handler := s3Lib.NewFromConfig(cfg)
+
+	_, err = s3Handler.PutObject(ctx, &s3Lib.PutObjectInput{
+		Bucket: aws.String(config.Config.SandstormStateBucket),
+		Key:    aws.String(getScaleSnapshotLocation()),
+		Body:   bytes.NewReader(data),
+	})
+
+	return err
+}
+
+func deleteScaleSnapshot(ctx context.Context, cfg aws.Config) error {
+	if config.Config.SandstormStateBucket == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+
+		return os.Remove(fmt.Sprintf("%s/%s", home, getScaleSnapshotLocation()))
+	}
+
+	s3Handler := s3Lib.NewFromConfig(cfg)
+
+	_, err := s3Handler.DeleteObject(ctx, &s3Lib.DeleteObjectInput{
+		Bucket: aws.String(config.Config.SandstormStateBucket),
+		Key:    aws.String(getScaleSnapshotLocation()),
+	})
+
+	return err
+}
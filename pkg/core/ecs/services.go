@@ -2,12 +2,19 @@ package ecs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	configPkg "github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/utils"
 )
 
 type Service struct {
@@ -19,6 +26,9 @@ type Service struct {
 	ClusterName          string
 	TaskDefinitions      []TaskDefinition
 	CanRevert            bool
+	// CurrentTaskDefinition and RevertTaskDefinition are the revisions the service runs and reverts to
+	CurrentTaskDefinition *TaskDefinition
+	RevertTaskDefinition  *TaskDefinition
 }
 
 func (s *Service) GetTaskDefintions(ctx context.Context, cfg aws.Config, revisionsToLookback int32) {
@@ -42,6 +52,16 @@ func (s *Service) GetTaskDefintions(ctx context.Context, cfg aws.Config, revisio
 
 	s.TaskDefinitions = make([]TaskDefinition, 0)
 
+	current, err := ecsHandler.DescribeTaskDefinition(ctx, &ecsLib.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(s.OldTaskDefinitionArn),
+	})
+	if err != nil {
+		return
+	}
+
+	currentTaskDefinition := newTaskDefinition(tdName, current.TaskDefinition)
+	s.CurrentTaskDefinition = &currentTaskDefinition
+
 	for _, td := range o1.TaskDefinitionArns {
 		o, err := ecsHandler.DescribeTaskDefinition(ctx, &ecsLib.DescribeTaskDefinitionInput{
 			TaskDefinition: aws.String(re.ReplaceAllString(td, "${1}")),
@@ -51,11 +71,48 @@ func (s *Service) GetTaskDefintions(ctx context.Context, cfg aws.Config, revisio
 			return
 		}
 
-		s.TaskDefinitions = append(s.TaskDefinitions, TaskDefinition{
-			Arn:     o.TaskDefinition.TaskDefinitionArn,
-			Name:    tdName,
-			Version: o.TaskDefinition.Revision,
-			Image:   *o.TaskDefinition.ContainerDefinitions[0].Image,
-		})
+		s.TaskDefinitions = append(s.TaskDefinitions, newTaskDefinition(tdName, o.TaskDefinition))
 	}
 }
+
+// resolveServiceName returns the service of the cluster matching serviceName, preferring an exact match
+// and asking the user to choose if several services match
+func resolveServiceName(ctx context.Context, cfg aws.Config, clusterName, serviceName string) (string, error) {
+	cluster := Cluster{Name: clusterName}
+	if err := cluster.GetServices(ctx, cfg, serviceName); err != nil {
+		return "", err
+	}
+
+	services := make([]string, 0)
+	for _, service := range cluster.Services {
+		if service.Name == serviceName {
+			return service.Name, nil
+		}
+
+		services = append(services, service.Name)
+	}
+
+	if len(services) == 0 {
+		return "", fmt.Errorf("no service %s found", logger.Underline(serviceName))
+	}
+
+	if len(services) == 1 {
+		return services[0], nil
+	}
+
+	sort.Strings(services)
+
+	logger.Info("Select service")
+	for i, service := range services {
+		fmt.Println(logger.Bold(i), ":", service)
+	}
+
+	choice := strings.TrimSpace(utils.GetUserInput("Enter Choice: "))
+
+	i, err := strconv.ParseInt(choice, 0, 32)
+	if err != nil || i < 0 || i >= int64(len(services)) {
+		return "", errors.New(logger.Bold("Invalid choice"))
+	}
+
+	return services[i], nil
+}
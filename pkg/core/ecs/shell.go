@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/mudrex/onyx/pkg/audit"
@@ -39,12 +40,9 @@ func spawnRemoteDockerContainerShell(ctx context.Context, host, serviceName, she
 	logger.Info("Spawning shell for %s on instance %s", logger.Underline(serviceName), host)
 
 	log := fmt.Sprintf("[ecs/spawn-shell] *%s* logged in to _%s_ for %s", utils.GetUser(), host, serviceName)
-	notifier.Notify(
-		config.Config.SlackHook,
-		log,
-	)
+	notifier.Notify(notifier.SeverityInfo, log)
 
-	audit.Log(ctx, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/spawn-shell", Target: serviceName + "@" + host, Outcome: audit.OutcomeSuccess, Message: log})
 
 	sshCmdDockerShell := fmt.Sprintf("sudo ssh -t -i %s -o StrictHostKeyChecking=no ec2-user@%s 'docker exec -it %s %s'", config.Config.PrivateKey, host, containerID, shell)
 	out1 := exec.Command("bash", "-c", sshCmdDockerShell)
@@ -91,7 +89,7 @@ func getServicesHosts(ctx context.Context, cfg aws.Config, serviceName, clusterN
 	return servicesHosts, nil
 }
 
-func SpawnServiceShell(ctx context.Context, cfg aws.Config, serviceName, clusterName, shell string) error {
+func SpawnServiceShell(ctx context.Context, cfg aws.Config, serviceName, clusterName, containerName, taskID, shell string) error {
 	if !strings.Contains(clusterName, config.Config.Environment) {
 		logger.Error("You are in %s environment but you are trying to access %s environment", logger.Underline(config.Config.Environment), clusterName)
 		return nil
@@ -114,6 +112,10 @@ func SpawnServiceShell(ctx context.Context, cfg aws.Config, serviceName, cluster
 		return nil
 	}
 
+	if getShellMode(clusterName) == shellModeExec {
+		return spawnExecShell(ctx, cfg, clusterName, serviceName, containerName, taskID, shell)
+	}
+
 	servicesHosts, err := getServicesHosts(ctx, cfg, serviceName, clusterName)
 	if err != nil {
 		return err
@@ -137,6 +139,39 @@ func SpawnServiceShell(ctx context.Context, cfg aws.Config, serviceName, cluster
 	return spawnRemoteDockerContainerShell(ctx, host, serviceName, shell)
 }
 
+// spawnExecShell opens the shell in the chosen container through ECS Exec, without needing access to its host
+func spawnExecShell(ctx context.Context, cfg aws.Config, clusterName, serviceName, containerName, taskID, shell string) error {
+	targets, err := getExecTargets(ctx, cfg, clusterName, serviceName, containerName, taskID)
+	if err != nil {
+		return err
+	}
+
+	if len(targets) == 0 {
+		return fmt.Errorf("no running task of %s found", logger.Underline(serviceName))
+	}
+
+	target, err := selectExecTarget(targets)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Spawning shell for %s in task %s", logger.Underline(target.Container), target.TaskID())
+
+	log := fmt.Sprintf("[ecs/spawn-shell] *%s* logged in to _%s_ of task %s for %s", utils.GetUser(), target.Container, target.TaskID(), target.ServiceName)
+	notifier.Notify(notifier.SeverityInfo, log)
+
+	audit.Log(ctx, audit.Entry{Command: "ecs/spawn-shell", Target: target.ServiceName + "@" + target.TaskArn, Outcome: audit.OutcomeSuccess, Message: log})
+
+	err = startExecSession(ctx, cfg, target, shell)
+	if err != nil {
+		return err
+	}
+
+	logger.Success("Exiting safely")
+
+	return nil
+}
+
 func getServiceHost(servicesHosts map[string][]string) (string, error) {
 	services := make([]string, 0)
 	for service := range servicesHosts {
@@ -184,11 +219,8 @@ func tailContainerLogs(ctx context.Context, host, serviceName string, tailLogs i
 	logger.Info("Spawning shell for %s on instance %s", logger.Underline(serviceName), host)
 
 	log := fmt.Sprintf("[ecs/tail-logs] *%s* tailed logs for %s on _%s_", utils.GetUser(), serviceName, host)
-	notifier.Notify(
-		config.Config.SlackHook,
-		log,
-	)
-	audit.Log(ctx, log)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ecs/tail-logs", Target: serviceName + "@" + host, Outcome: audit.OutcomeSuccess, Message: log})
 
 	sshCmdDockerShell := fmt.Sprintf("sudo ssh -t -i %s -o StrictHostKeyChecking=no ec2-user@%s 'docker logs -f %s --tail %d'", config.Config.PrivateKey, host, containerID, tailLogs)
 	out1 := exec.Command("bash", "-c", sshCmdDockerShell)
@@ -203,7 +235,9 @@ func tailContainerLogs(ctx context.Context, host, serviceName string, tailLogs i
 	return nil
 }
 
-func TailContainerLogs(ctx context.Context, cfg aws.Config, serviceName, clusterName string, tailLogs int32) error {
+// TailContainerLogs tails the logs of the service, following every task through CloudWatch Logs if the cluster's
+// logs_mode is cloudwatch or useCloudWatch is set, else a single container through its host
+func TailContainerLogs(ctx context.Context, cfg aws.Config, serviceName, clusterName, containerName string, tailLogs int32, since time.Duration, filter string, raw, useCloudWatch bool) error {
 	if !strings.Contains(clusterName, config.Config.Environment) {
 		logger.Error("You are in %s environment but you are trying to access %s environment", logger.Underline(config.Config.Environment), clusterName)
 		return nil
@@ -226,6 +260,10 @@ func TailContainerLogs(ctx context.Context, cfg aws.Config, serviceName, cluster
 		return nil
 	}
 
+	if useCloudWatch || getLogsMode(clusterName) == logsModeCloudWatch {
+		return followCloudWatchLogs(ctx, cfg, clusterName, serviceName, containerName, tailLogs, since, filter, raw)
+	}
+
 	servicesHosts, err := getServicesHosts(ctx, cfg, serviceName, clusterName)
 	if err != nil {
 		return err
@@ -3,9 +3,11 @@ package ecs
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
 type Task struct {
@@ -16,16 +18,99 @@ type Task struct {
 }
 
 type TaskDefinition struct {
-	Arn     *string
-	Name    string
-	Version int32
-	Image   string
+	Arn        *string
+	Name       string
+	Version    int32
+	Containers []ContainerImage
+}
+
+type ContainerImage struct {
+	Name  string
+	Image string
+}
+
+func newTaskDefinition(name string, taskDefinition *types.TaskDefinition) TaskDefinition {
+	containers := make([]ContainerImage, 0)
+	for _, containerDefinition := range taskDefinition.ContainerDefinitions {
+		containers = append(containers, ContainerImage{
+			Name:  aws.ToString(containerDefinition.Name),
+			Image: aws.ToString(containerDefinition.Image),
+		})
+	}
+
+	return TaskDefinition{
+		Arn:        taskDefinition.TaskDefinitionArn,
+		Name:       name,
+		Version:    taskDefinition.Revision,
+		Containers: containers,
+	}
 }
 
 func (td *TaskDefinition) GetNameWithVersion() string {
 	return fmt.Sprintf("%s:%d", td.Name, td.Version)
 }
 
+// GetImage returns the image of the container
+func (td *TaskDefinition) GetImage(containerName string) (string, bool) {
+	for _, container := range td.Containers {
+		if container.Name == containerName {
+			return container.Image, true
+		}
+	}
+
+	return "", false
+}
+
+// ResolveContainer returns containerName if present, else the container named after the service,
+// else the only container of the task definition
+func (td *TaskDefinition) ResolveContainer(serviceName, containerName string) (string, error) {
+	names := make([]string, 0)
+	for _, container := range td.Containers {
+		names = append(names, container.Name)
+	}
+
+	if containerName != "" {
+		if _, ok := td.GetImage(containerName); ok {
+			return containerName, nil
+		}
+
+		return "", fmt.Errorf("no container %s, available: %s", containerName, strings.Join(names, ", "))
+	}
+
+	if _, ok := td.GetImage(serviceName); ok {
+		return serviceName, nil
+	}
+
+	if len(names) == 1 {
+		return names[0], nil
+	}
+
+	return "", fmt.Errorf("multiple containers, choose one with --container: %s", strings.Join(names, ", "))
+}
+
+// getImageTag returns the tag of the image, empty for images pinned by digest
+func getImageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+
+	if _, tag := splitImageTag(image); tag != "" {
+		return tag
+	}
+
+	return "latest"
+}
+
+// splitImageTag splits image into its repository and tag, the tag is empty when image has none
+func splitImageTag(image string) (string, string) {
+	// the registry host can have a port, so only a colon after the last slash starts the tag
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+
+	return image, ""
+}
+
 func DescribeTasks(ctx context.Context, cfg aws.Config, clusterName string, services *[]Service) *map[string]Task {
 	ecsHandler := ecsLib.NewFromConfig(cfg)
 
@@ -0,0 +1,332 @@
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ecsLib "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mudrex/onyx/pkg/logger"
+)
+
+// FieldChange is a single field which differs between two revisions. From is empty for added
+// fields and To is empty for removed ones, fields can be set to empty values as well.
+type FieldChange struct {
+	Field string `json:"field"`
+	// Kind is added, removed or changed
+	Kind string `json:"kind"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ContainerDiff struct {
+	Name string `json:"name"`
+	// Status is added, removed or changed
+	Status  string        `json:"status"`
+	Changes []FieldChange `json:"changes"`
+}
+
+type TaskDefinitionDiff struct {
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Changes    []FieldChange   `json:"changes"`
+	Containers []ContainerDiff `json:"containers"`
+}
+
+func (d *TaskDefinitionDiff) IsEmpty() bool {
+	return len(d.Changes) == 0 && len(d.Containers) == 0
+}
+
+func (d *TaskDefinitionDiff) Print() {
+	logger.Info("Changes from %s -> %s", logger.Italic(d.From), logger.Bold(d.To))
+
+	if d.IsEmpty() {
+		logger.Success("No changes")
+		return
+	}
+
+	fmt.Println("|-----------------------------------------------------")
+	printFieldChanges(d.Changes, "| ")
+
+	for _, container := range d.Containers {
+		switch container.Status {
+		case "added":
+			fmt.Println(logger.Green(fmt.Sprintf("| + Container %s", container.Name)))
+		case "removed":
+			fmt.Println(logger.Red(fmt.Sprintf("| - Container %s", container.Name)))
+		default:
+			fmt.Println("|", logger.Bold("Container "+container.Name))
+		}
+
+		printFieldChanges(container.Changes, "|    ")
+	}
+	fmt.Println("|-----------------------------------------------------")
+}
+
+func printFieldChanges(changes []FieldChange, indent string) {
+	for _, change := range changes {
+		switch change.Kind {
+		case "added":
+			fmt.Println(logger.Green(fmt.Sprintf("%s+ %s: %s", indent, change.Field, change.To)))
+		case "removed":
+			fmt.Println(logger.Red(fmt.Sprintf("%s- %s: %s", indent, change.Field, change.From)))
+		default:
+			fmt.Println(fmt.Sprintf("%s~ %s: %s -> %s", indent, change.Field, logger.Italic(change.From), logger.Bold(change.To)))
+		}
+	}
+}
+
+// diffFields returns the changes between the flattened fields, sorted by field
+func diffFields(from, to map[string]string) []FieldChange {
+	fields := make(map[string]bool)
+	for field := range from {
+		fields[field] = true
+	}
+
+	for field := range to {
+		fields[field] = true
+	}
+
+	sortedFields := make([]string, 0)
+	for field := range fields {
+		sortedFields = append(sortedFields, field)
+	}
+
+	sort.Strings(sortedFields)
+
+	changes := make([]FieldChange, 0)
+	for _, field := range sortedFields {
+		fromValue, inFrom := from[field]
+		toValue, inTo := to[field]
+
+		switch {
+		case !inFrom:
+			changes = append(changes, FieldChange{Field: field, Kind: "added", To: toValue})
+		case !inTo:
+			changes = append(changes, FieldChange{Field: field, Kind: "removed", From: fromValue})
+		case fromValue != toValue:
+			changes = append(changes, FieldChange{Field: field, Kind: "changed", From: fromValue, To: toValue})
+		}
+	}
+
+	return changes
+}
+
+// flattenTaskDefinition returns the task level fields compared by the diff
+func flattenTaskDefinition(taskDefinition *types.TaskDefinition) map[string]string {
+	fields := make(map[string]string)
+	setCountField(fields, "cpu", aws.ToString(taskDefinition.Cpu))
+	setCountField(fields, "memory", aws.ToString(taskDefinition.Memory))
+	setField(fields, "network_mode", string(taskDefinition.NetworkMode))
+	setField(fields, "task_role_arn", aws.ToString(taskDefinition.TaskRoleArn))
+	setField(fields, "execution_role_arn", aws.ToString(taskDefinition.ExecutionRoleArn))
+
+	compatibilities := make([]string, 0)
+	for _, compatibility := range taskDefinition.RequiresCompatibilities {
+		compatibilities = append(compatibilities, string(compatibility))
+	}
+
+	setField(fields, "requires_compatibilities", strings.Join(compatibilities, ", "))
+
+	return fields
+}
+
+// flattenContainerDefinition returns the container fields compared by the diff, with one field
+// per environment variable, secret and log option. Those are kept even when empty, as setting one
+// to an empty value is a change.
+func flattenContainerDefinition(containerDefinition types.ContainerDefinition) map[string]string {
+	fields := make(map[string]string)
+	setField(fields, "image", aws.ToString(containerDefinition.Image))
+	setCountField(fields, "cpu", strconv.Itoa(int(containerDefinition.Cpu)))
+	setField(fields, "command", strings.Join(containerDefinition.Command, " "))
+	setField(fields, "entry_point", strings.Join(containerDefinition.EntryPoint, " "))
+
+	if containerDefinition.Memory != nil {
+		setField(fields, "memory", strconv.Itoa(int(*containerDefinition.Memory)))
+	}
+
+	if containerDefinition.MemoryReservation != nil {
+		setField(fields, "memory_reservation", strconv.Itoa(int(*containerDefinition.MemoryReservation)))
+	}
+
+	if containerDefinition.Essential != nil {
+		setField(fields, "essential", strconv.FormatBool(*containerDefinition.Essential))
+	}
+
+	for _, variable := range containerDefinition.Environment {
+		fields["environment."+aws.ToString(variable.Name)] = aws.ToString(variable.Value)
+	}
+
+	for _, secret := range containerDefinition.Secrets {
+		fields["secrets."+aws.ToString(secret.Name)] = aws.ToString(secret.ValueFrom)
+	}
+
+	portMappings := make([]string, 0)
+	for _, portMapping := range containerDefinition.PortMappings {
+		portMappings = append(portMappings, fmt.Sprintf(
+			"%d:%d/%s",
+			aws.ToInt32(portMapping.HostPort),
+			aws.ToInt32(portMapping.ContainerPort),
+			portMapping.Protocol,
+		))
+	}
+
+	sort.Strings(portMappings)
+	setField(fields, "port_mappings", strings.Join(portMappings, ", "))
+
+	if containerDefinition.LogConfiguration != nil {
+		setField(fields, "log_driver", string(containerDefinition.LogConfiguration.LogDriver))
+		for key, value := range containerDefinition.LogConfiguration.Options {
+			fields["log_options."+key] = value
+		}
+	}
+
+	return fields
+}
+
+// setField leaves out unset fields
+func setField(fields map[string]string, field, value string) {
+	if value != "" {
+		fields[field] = value
+	}
+}
+
+// setCountField leaves out unset cpu and memory, which ecs returns as 0
+func setCountField(fields map[string]string, field, value string) {
+	if value != "0" {
+		setField(fields, field, value)
+	}
+}
+
+func diffTaskDefinitions(from, to *types.TaskDefinition) TaskDefinitionDiff {
+	diff := TaskDefinitionDiff{
+		From:       fmt.Sprintf("%s:%d", aws.ToString(from.Family), from.Revision),
+		To:         fmt.Sprintf("%s:%d", aws.ToString(to.Family), to.Revision),
+		Changes:    diffFields(flattenTaskDefinition(from), flattenTaskDefinition(to)),
+		Containers: make([]ContainerDiff, 0),
+	}
+
+	fromContainers := make(map[string]map[string]string)
+	toContainers := make(map[string]map[string]string)
+	names := make([]string, 0)
+
+	for _, containerDefinition := range from.ContainerDefinitions {
+		name := aws.ToString(containerDefinition.Name)
+		fromContainers[name] = flattenContainerDefinition(containerDefinition)
+		names = append(names, name)
+	}
+
+	for _, containerDefinition := range to.ContainerDefinitions {
+		name := aws.ToString(containerDefinition.Name)
+		toContainers[name] = flattenContainerDefinition(containerDefinition)
+		if _, ok := fromContainers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		fromFields, inFrom := fromContainers[name]
+		toFields, inTo := toContainers[name]
+
+		status := "changed"
+		if !inFrom {
+			status = "added"
+		} else if !inTo {
+			status = "removed"
+		}
+
+		changes := diffFields(fromFields, toFields)
+		if len(changes) > 0 {
+			diff.Containers = append(diff.Containers, ContainerDiff{Name: name, Status: status, Changes: changes})
+		}
+	}
+
+	return diff
+}
+
+func describeTaskDefinition(ctx context.Context, cfg aws.Config, taskDefinition string) (*types.TaskDefinition, error) {
+	ecsHandler := ecsLib.NewFromConfig(cfg)
+
+	output, err := ecsHandler.DescribeTaskDefinition(ctx, &ecsLib.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(taskDefinition),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.TaskDefinition, nil
+}
+
+// DiffTaskDefinitions prints the diff between two revisions, given as family:revision or ARN
+func DiffTaskDefinitions(ctx context.Context, cfg aws.Config, from, to string, asJSON bool) error {
+	fromTaskDefinition, err := describeTaskDefinition(ctx, cfg, from)
+	if err != nil {
+		return err
+	}
+
+	toTaskDefinition, err := describeTaskDefinition(ctx, cfg, to)
+	if err != nil {
+		return err
+	}
+
+	diff := diffTaskDefinitions(fromTaskDefinition, toTaskDefinition)
+
+	return printTaskDefinitionDiff(&diff, asJSON)
+}
+
+// DiffServiceTaskDefinitions prints the diff between the revision the service runs and the revision
+// of the family revisionsToLookback revisions before the latest
+func DiffServiceTaskDefinitions(ctx context.Context, cfg aws.Config, clusterName, serviceName string, revisionsToLookback int32, asJSON bool) error {
+	if revisionsToLookback > 50 {
+		return errors.New("please limit your lookback to 50")
+	}
+
+	serviceName, err := resolveServiceName(ctx, cfg, clusterName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	cluster := Cluster{Name: clusterName}
+	if err := cluster.GetServices(ctx, cfg, serviceName); err != nil {
+		return err
+	}
+
+	for _, service := range cluster.Services {
+		if service.Name != serviceName {
+			continue
+		}
+
+		service.GetTaskDefintions(ctx, cfg, revisionsToLookback+1)
+		if service.CurrentTaskDefinition == nil || len(service.TaskDefinitions) == 0 {
+			return fmt.Errorf("unable to get task definitions of %s", logger.Underline(serviceName))
+		}
+
+		past := service.TaskDefinitions[len(service.TaskDefinitions)-1]
+
+		return DiffTaskDefinitions(ctx, cfg, past.GetNameWithVersion(), service.CurrentTaskDefinition.GetNameWithVersion(), asJSON)
+	}
+
+	return fmt.Errorf("no service %s found", logger.Underline(serviceName))
+}
+
+func printTaskDefinitionDiff(diff *TaskDefinitionDiff, asJSON bool) error {
+	if !asJSON {
+		diff.Print()
+		return nil
+	}
+
+	diffBytes, err := json.MarshalIndent(diff, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(diffBytes))
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// Drift is a grant that differs between the lock file and the database
+type Drift struct {
+	Alias    string
+	Username string
+	Table    string
+	Grant    string
+	Columns  []string
+	// IsExtra is true if the grant is present in the database but not in the lock
+	IsExtra bool
+}
+
+func (d *Drift) String() string {
+	kind := "missing"
+	if d.IsExtra {
+		kind = "extra"
+	}
+
+	return fmt.Sprintf("%s %s on %s (%s)", kind, d.Grant, d.Table, strings.Join(d.Columns, ", "))
+}
+
+// DetectDrift compares the live grants of every user in the access config with the lock file
+func DetectDrift(ctx context.Context, cfg aws.Config, accessType string, notify bool) error {
+	accessConfig, err := getAccessConfig(accessType)
+	if err != nil {
+		return err
+	}
+
+	_, loadedConfig, isAliased, err := loadAccessConfig(accessConfig)
+	if err != nil {
+		return err
+	}
+
+	configLock, _, err := loadConfigLock(accessConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := loadCriticalTables(); err != nil {
+		return err
+	}
+
+	defer disconnect()
+
+	lockedConfigs := configLock.getLocked(isAliased)
+	drifts := make([]Drift, 0)
+
+	aliases := make([]string, 0)
+	for alias := range loadedConfig {
+		aliases = append(aliases, alias)
+	}
+
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		if err := useDatabase(ctx, cfg, alias); err != nil {
+			return err
+		}
+
+		db, err := connect(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		for _, username := range sortedUsernames(loadedConfig[alias]) {
+			lockedGrants, ok := lockedConfigs[alias][username]
+			if !ok {
+				logger.Warn("%s/%s is not applied yet, skipping", alias, username)
+				continue
+			}
+
+			engine := getEngine(databaseSecret)
+
+			liveGrants, err := engine.readGrants(ctx, db, databaseSecret, username)
+			if err != nil {
+				return err
+			}
+
+			if lockedGrants, err = engine.expandGrants(ctx, db, lockedGrants); err != nil {
+				return err
+			}
+
+			if liveGrants, err = engine.expandGrants(ctx, db, liveGrants); err != nil {
+				return err
+			}
+
+			drifts = append(drifts, diffGrants(alias, username, lockedGrants, liveGrants)...)
+		}
+	}
+
+	if len(drifts) == 0 {
+		logger.Success("No drift detected for %s", logger.Underline(accessConfig))
+		return nil
+	}
+
+	printDrifts(drifts)
+
+	if notify {
+		criticalDrifts := make([]string, 0)
+		for _, drift := range drifts {
+			if _, ok := CriticalTables[drift.Table]; ok {
+				criticalDrifts = append(criticalDrifts, fmt.Sprintf("%s/%s: %s", drift.Alias, drift.Username, drift.String()))
+			}
+		}
+
+		if len(criticalDrifts) > 0 {
+			notifier.Notify(
+				notifier.SeverityCritical,
+				fmt.Sprintf(":bangbang: [rds/drift] grants on critical tables have drifted from %s\n%s", accessConfig, strings.Join(criticalDrifts, "\n")),
+			)
+		}
+	}
+
+	return fmt.Errorf("%d grant(s) drifted from %s", len(drifts), logger.Underline(accessConfig+".lock"))
+}
+
+// diffGrants lists the grants missing from the database and the extra ones present only in the database
+func diffGrants(alias, username string, lockedGrants, liveGrants map[string]map[string][]string) []Drift {
+	drifts := make([]Drift, 0)
+
+	locked := normalizeGrants(lockedGrants)
+	live := normalizeGrants(liveGrants)
+
+	tablesMap := make(map[string]bool)
+	for tableName := range locked {
+		tablesMap[tableName] = true
+	}
+
+	for tableName := range live {
+		tablesMap[tableName] = true
+	}
+
+	tableNames := make([]string, 0)
+	for tableName := range tablesMap {
+		tableNames = append(tableNames, tableName)
+	}
+
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		grantsMap := make(map[string]bool)
+		for grant := range locked[tableName] {
+			grantsMap[grant] = true
+		}
+
+		for grant := range live[tableName] {
+			grantsMap[grant] = true
+		}
+
+		grants := make([]string, 0)
+		for grant := range grantsMap {
+			grants = append(grants, grant)
+		}
+
+		sort.Strings(grants)
+
+		for _, grant := range grants {
+			missing := utils.GetStringAMinusB(locked[tableName][grant], live[tableName][grant])
+			if len(missing) > 0 {
+				sort.Strings(missing)
+				drifts = append(drifts, Drift{Alias: alias, Username: username, Table: tableName, Grant: grant, Columns: missing})
+			}
+
+			extra := utils.GetStringAMinusB(live[tableName][grant], locked[tableName][grant])
+			if len(extra) > 0 {
+				sort.Strings(extra)
+				drifts = append(drifts, Drift{Alias: alias, Username: username, Table: tableName, Grant: grant, Columns: extra, IsExtra: true})
+			}
+		}
+	}
+
+	return drifts
+}
+
+func normalizeGrants(grants map[string]map[string][]string) map[string]map[string][]string {
+	normalized := make(map[string]map[string][]string)
+	for tableName, tableGrants := range grants {
+		for grant, columns := range tableGrants {
+			for _, column := range columns {
+				addGrant(normalized, tableName, grant, column)
+			}
+		}
+	}
+
+	return normalized
+}
+
+func printDrifts(drifts []Drift) {
+	logger.Warn("Drift detected between lock and live grants")
+	fmt.Println("|-----------------------------------------------------")
+
+	lastUser := ""
+	for _, drift := range drifts {
+		if user := drift.Alias + "/" + drift.Username; user != lastUser {
+			fmt.Println("|", logger.Bold(user))
+			lastUser = user
+		}
+
+		line := fmt.Sprintf("|  - %s", drift.String())
+		if drift.IsExtra {
+			line = fmt.Sprintf("|  + %s", drift.String())
+		}
+
+		fmt.Print(logger.Red(line))
+		if _, ok := CriticalTables[drift.Table]; ok {
+			fmt.Print(logger.Bold("    <------- critical table"))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("|-----------------------------------------------------")
+}
@@ -0,0 +1,113 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// engine generates the engine specific queries for managing users and their grants
+type engine interface {
+	open(secret Database) (*sql.DB, error)
+	createUserQueries(secret Database, username, password string) []string
+	dropUserQueries(secret Database, username string) []string
+	changePasswordQuery(username, password string) string
+	// schemaQueries returns the queries required before any table of tableNames can be granted
+	schemaQueries(username string, tableNames []string) []string
+	grantQuery(secret Database, username, tableName, grant string, columns []string, isGrant bool) string
+	// readGrants reads the live grants of the user in the same table -> grant -> columns shape as the access config
+	readGrants(ctx context.Context, db *sql.DB, secret Database, username string) (map[string]map[string][]string, error)
+	// expandGrants spells out ALL and wildcard tables in the privileges and tables readGrants lists them as,
+	// so that grants from the lock compare with the live ones
+	expandGrants(ctx context.Context, db *sql.DB, grants map[string]map[string][]string) (map[string]map[string][]string, error)
+}
+
+var dbConnection *sql.DB
+
+// getEngine returns the engine based on the engine field of the RDS secret. Defaults to MySQL.
+func getEngine(secret Database) engine {
+	if strings.Contains(strings.ToLower(secret.Engine), "postgres") {
+		return postgresEngine{}
+	}
+
+	return mysqlEngine{}
+}
+
+func connect(ctx context.Context, cfg aws.Config) (*sql.DB, error) {
+	if dbConnection != nil {
+		return dbConnection, nil
+	}
+
+	if databaseSecret.Host == "" {
+		return nil, errors.New("database credentials not loaded")
+	}
+
+	db, err := getEngine(databaseSecret).open(databaseSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	dbConnection = db
+
+	return dbConnection, nil
+}
+
+func disconnect() {
+	if dbConnection == nil {
+		return
+	}
+
+	dbConnection.Close()
+	dbConnection = nil
+}
+
+func runQuery(ctx context.Context, cfg aws.Config, query string) error {
+	db, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx, query)
+	return err
+}
+
+// runQueries runs the queries in order and stops at the first failure
+func runQueries(ctx context.Context, cfg aws.Config, queries []string) error {
+	for _, query := range queries {
+		if err := runQuery(ctx, cfg, query); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isAllPrivileges(grant string) bool {
+	grant = strings.ToUpper(grant)
+	return grant == "ALL" || grant == "ALL PRIVILEGES"
+}
+
+func addGrant(grants map[string]map[string][]string, tableName, grant, column string) {
+	if _, ok := grants[tableName]; !ok {
+		grants[tableName] = make(map[string][]string)
+	}
+
+	grant = strings.ToUpper(grant)
+	for _, existing := range grants[tableName][grant] {
+		if existing == column {
+			return
+		}
+	}
+
+	grants[tableName][grant] = append(grants[tableName][grant], column)
+}
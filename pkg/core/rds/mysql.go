@@ -0,0 +1,186 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+type mysqlEngine struct{}
+
+// mysqlSchemaPrivileges and mysqlTablePrivileges are what ALL grants on db.* and on a table
+var (
+	mysqlSchemaPrivileges = []string{
+		"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER", "CREATE TEMPORARY TABLES",
+		"LOCK TABLES", "EXECUTE", "CREATE VIEW", "SHOW VIEW", "CREATE ROUTINE", "ALTER ROUTINE", "EVENT", "TRIGGER",
+	}
+	mysqlTablePrivileges = []string{
+		"SELECT", "INSERT", "UPDATE", "DELETE", "CREATE", "DROP", "REFERENCES", "INDEX", "ALTER", "CREATE VIEW", "SHOW VIEW", "TRIGGER",
+	}
+)
+
+func (mysqlEngine) open(secret Database) (*sql.DB, error) {
+	port := secret.Port
+	if port == 0 {
+		port = 3306
+	}
+
+	mysqlConfig := mysql.NewConfig()
+	mysqlConfig.User = secret.Username
+	mysqlConfig.Passwd = secret.Password
+	mysqlConfig.Net = "tcp"
+	mysqlConfig.Addr = fmt.Sprintf("%s:%d", secret.Host, port)
+	mysqlConfig.Timeout = 10 * time.Second
+
+	return sql.Open("mysql", mysqlConfig.FormatDSN())
+}
+
+func (e mysqlEngine) createUserQueries(secret Database, username, password string) []string {
+	return []string{
+		fmt.Sprintf("CREATE USER %s IDENTIFIED BY %s", e.quoteUser(username), e.quoteString(password)),
+	}
+}
+
+func (e mysqlEngine) dropUserQueries(secret Database, username string) []string {
+	return []string{
+		fmt.Sprintf("DROP USER %s", e.quoteUser(username)),
+	}
+}
+
+func (e mysqlEngine) changePasswordQuery(username, password string) string {
+	return fmt.Sprintf("ALTER USER %s IDENTIFIED BY %s", e.quoteUser(username), e.quoteString(password))
+}
+
+func (mysqlEngine) schemaQueries(username string, tableNames []string) []string {
+	return nil
+}
+
+func (e mysqlEngine) grantQuery(secret Database, username, tableName, grant string, columns []string, isGrant bool) string {
+	permission := "GRANT"
+	permissionHelper := "TO"
+	if !isGrant {
+		permission = "REVOKE"
+		permissionHelper = "FROM"
+	}
+
+	if len(columns) == 1 && columns[0] == "*" {
+		return fmt.Sprintf("%s %s ON %s %s %s", permission, grant, e.quoteTable(secret.DBName, tableName), permissionHelper, e.quoteUser(username))
+	}
+
+	quotedColumns := make([]string, 0)
+	for _, column := range columns {
+		quotedColumns = append(quotedColumns, e.quoteIdentifier(column))
+	}
+
+	return fmt.Sprintf("%s %s (%s) ON %s %s %s", permission, grant, strings.Join(quotedColumns, ", "), e.quoteTable(secret.DBName, tableName), permissionHelper, e.quoteUser(username))
+}
+
+// quoteIdentifier quotes database, table and column names
+func (mysqlEngine) quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// quoteString quotes user names and passwords used as string literals
+func (mysqlEngine) quoteString(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+func (e mysqlEngine) quoteUser(username string) string {
+	return e.quoteString(username) + "@'%'"
+}
+
+func (e mysqlEngine) quoteTable(dbname, tableName string) string {
+	if tableName == "*" {
+		return e.quoteIdentifier(dbname) + ".*"
+	}
+
+	return e.quoteIdentifier(dbname) + "." + e.quoteIdentifier(tableName)
+}
+
+func (mysqlEngine) expandGrants(ctx context.Context, db *sql.DB, grants map[string]map[string][]string) (map[string]map[string][]string, error) {
+	expanded := make(map[string]map[string][]string)
+	for tableName, tableGrants := range grants {
+		for grant, columns := range tableGrants {
+			privileges := []string{grant}
+			if isAllPrivileges(grant) {
+				privileges = mysqlTablePrivileges
+				if tableName == "*" {
+					privileges = mysqlSchemaPrivileges
+				}
+			}
+
+			for _, privilege := range privileges {
+				for _, column := range columns {
+					addGrant(expanded, tableName, privilege, column)
+				}
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+func (mysqlEngine) readGrants(ctx context.Context, db *sql.DB, secret Database, username string) (map[string]map[string][]string, error) {
+	grants := make(map[string]map[string][]string)
+	grantee := fmt.Sprintf("'%s'@'%%'", username)
+
+	schemaRows, err := db.QueryContext(ctx, "SELECT PRIVILEGE_TYPE FROM information_schema.SCHEMA_PRIVILEGES WHERE GRANTEE = ? AND TABLE_SCHEMA = ?", grantee, secret.DBName)
+	if err != nil {
+		return nil, err
+	}
+	defer schemaRows.Close()
+
+	for schemaRows.Next() {
+		var privilege string
+		if err := schemaRows.Scan(&privilege); err != nil {
+			return nil, err
+		}
+
+		addGrant(grants, "*", privilege, "*")
+	}
+
+	if err := schemaRows.Err(); err != nil {
+		return nil, err
+	}
+
+	tableRows, err := db.QueryContext(ctx, "SELECT TABLE_NAME, PRIVILEGE_TYPE FROM information_schema.TABLE_PRIVILEGES WHERE GRANTEE = ? AND TABLE_SCHEMA = ?", grantee, secret.DBName)
+	if err != nil {
+		return nil, err
+	}
+	defer tableRows.Close()
+
+	for tableRows.Next() {
+		var tableName, privilege string
+		if err := tableRows.Scan(&tableName, &privilege); err != nil {
+			return nil, err
+		}
+
+		addGrant(grants, tableName, privilege, "*")
+	}
+
+	if err := tableRows.Err(); err != nil {
+		return nil, err
+	}
+
+	columnRows, err := db.QueryContext(ctx, "SELECT TABLE_NAME, COLUMN_NAME, PRIVILEGE_TYPE FROM information_schema.COLUMN_PRIVILEGES WHERE GRANTEE = ? AND TABLE_SCHEMA = ?", grantee, secret.DBName)
+	if err != nil {
+		return nil, err
+	}
+	defer columnRows.Close()
+
+	for columnRows.Next() {
+		var tableName, columnName, privilege string
+		if err := columnRows.Scan(&tableName, &columnName, &privilege); err != nil {
+			return nil, err
+		}
+
+		addGrant(grants, tableName, privilege, columnName)
+	}
+
+	return grants, columnRows.Err()
+}
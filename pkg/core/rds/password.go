@@ -0,0 +1,130 @@
+package rds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/core/secretsmanager"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// defaultUserSecretTemplate is used when rds_user_secret_template is not set in onyx config
+const defaultUserSecretTemplate = "onyx/rds/{alias}/{username}"
+
+// getUserSecretName returns the name of the secret holding the credentials of username,
+// replacing {alias} and {username} in the configured template
+func getUserSecretName(alias, username string) string {
+	template := config.Config.RDSUserSecretTemplate
+	if template == "" {
+		template = defaultUserSecretTemplate
+	}
+
+	return strings.NewReplacer("{alias}", alias, "{username}", username).Replace(template)
+}
+
+// storeUserSecret writes the credentials of username to its secret, tagged with the owner and database
+func storeUserSecret(ctx context.Context, cfg aws.Config, alias, username, password string) error {
+	credentials := Database{
+		Username: username,
+		Password: password,
+		Host:     databaseSecret.Host,
+		Port:     databaseSecret.Port,
+		DBName:   databaseSecret.DBName,
+		Engine:   databaseSecret.Engine,
+	}
+
+	credentialsBytes, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+
+	secretName := getUserSecretName(alias, username)
+	err = secretsmanager.PutSecret(ctx, cfg, secretName, string(credentialsBytes), map[string]string{
+		"owner":      username,
+		"database":   alias,
+		"managed-by": "onyx",
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Stored credentials of %s in %s", username, logger.Underline(secretName))
+
+	return nil
+}
+
+// isDeclaredUser returns whether username has access to the database alias in the users or services access config
+func isDeclaredUser(alias, username string) (bool, error) {
+	for _, accessConfig := range []string{config.Config.RDSAccessConfig, config.Config.RDSServicesAccessConfig} {
+		if accessConfig == "" {
+			continue
+		}
+
+		_, loadedConfig, _, err := loadAccessConfig(accessConfig)
+		if err != nil {
+			return false, err
+		}
+
+		if _, ok := loadedConfig[alias][username]; ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// RotatePassword resets the password of username in the database alias and updates its secret. Only users of
+// the access configs are rotated, never the master user onyx itself connects as.
+func RotatePassword(ctx context.Context, cfg aws.Config, alias, username string) error {
+	isDeclared, err := isDeclaredUser(alias, username)
+	if err != nil {
+		return err
+	}
+
+	if !isDeclared {
+		return fmt.Errorf("%s is not a user of %s in the access configs", username, alias)
+	}
+
+	if err := useDatabase(ctx, cfg, alias); err != nil {
+		return err
+	}
+
+	defer disconnect()
+
+	if username == databaseSecret.Username {
+		return fmt.Errorf("%s is the master user of %s and can not be rotated", username, alias)
+	}
+
+	newPassword := utils.GetRandomStringWithSymbols(40)
+	err = runQuery(ctx, cfg, getEngine(databaseSecret).changePasswordQuery(username, newPassword))
+	if err != nil {
+		log := fmt.Sprintf(":bangbang: [rds/rotate-password] *%s* failed to reset the password of %s in %s: %s", utils.GetUser(), username, alias, err.Error())
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "rds/rotate-password", Target: username + "@" + alias, Outcome: audit.OutcomeFailure, Message: log})
+
+		return fmt.Errorf("unable to reset password of %s. Error: %s", username, err.Error())
+	}
+
+	logger.Success("Reset password of %s in %s", logger.Bold(username), logger.Bold(alias))
+
+	if err := storeUserSecret(ctx, cfg, alias, username, newPassword); err != nil {
+		log := fmt.Sprintf(":bangbang: [rds/rotate-password] *%s* reset the password of %s in %s but failed to store it: %s", utils.GetUser(), username, alias, err.Error())
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "rds/rotate-password", Target: username + "@" + alias, Outcome: audit.OutcomeFailure, Message: log})
+
+		return err
+	}
+
+	log := fmt.Sprintf("[rds/rotate-password] *%s* rotated the password of %s in %s", utils.GetUser(), username, alias)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "rds/rotate-password", Target: username + "@" + alias, Outcome: audit.OutcomeSuccess, Message: log})
+
+	return nil
+}
@@ -0,0 +1,362 @@
+package rds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+// Plan holds the exact changes required to bring the databases in line with the access config.
+// Checksums of the access config and its lock are recorded so that a stale plan is never applied.
+type Plan struct {
+	AccessConfig   string         `json:"access_config"`
+	ConfigChecksum string         `json:"config_checksum"`
+	LockChecksum   string         `json:"lock_checksum"`
+	Databases      []DatabasePlan `json:"databases"`
+}
+
+// DatabasePlan holds the changes for a single database alias of the access config
+type DatabasePlan struct {
+	Alias         string      `json:"alias"`
+	Engine        string      `json:"engine"`
+	DBName        string      `json:"dbname"`
+	UsersToCreate []string    `json:"users_to_create"`
+	UsersToDrop   []string    `json:"users_to_drop"`
+	Grants        []statement `json:"grants"`
+	Revokes       []statement `json:"revokes"`
+}
+
+func getAccessConfig(accessType string) (string, error) {
+	switch accessType {
+	case "users":
+		return config.Config.RDSAccessConfig, nil
+	case "services":
+		return config.Config.RDSServicesAccessConfig, nil
+	}
+
+	return "", errors.New("Invalid type " + accessType)
+}
+
+func newPlan(ctx context.Context, cfg aws.Config, accessConfig string) (*Plan, error) {
+	configData, loadedConfig, isAliased, err := loadAccessConfig(accessConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	configLock, lockChecksum, err := loadConfigLock(accessConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := Plan{
+		AccessConfig:   accessConfig,
+		ConfigChecksum: utils.GetSHA512Checksum([]byte(configData)),
+		LockChecksum:   lockChecksum,
+		Databases:      make([]DatabasePlan, 0),
+	}
+
+	// Verify checksum to prevent extra work
+	if plan.ConfigChecksum == configLock.Checksum {
+		return &plan, nil
+	}
+
+	if err := loadCriticalTables(); err != nil {
+		return nil, err
+	}
+
+	lockedConfigs := configLock.getLocked(isAliased)
+
+	aliasesMap := make(map[string]bool)
+	for alias := range loadedConfig {
+		aliasesMap[alias] = true
+	}
+
+	for alias := range lockedConfigs {
+		aliasesMap[alias] = true
+	}
+
+	aliases := make([]string, 0)
+	for alias := range aliasesMap {
+		aliases = append(aliases, alias)
+	}
+
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		if err := useDatabase(ctx, cfg, alias); err != nil {
+			return nil, err
+		}
+
+		databasePlan := DatabasePlan{
+			Alias:         alias,
+			Engine:        databaseSecret.Engine,
+			DBName:        databaseSecret.DBName,
+			UsersToCreate: make([]string, 0),
+			UsersToDrop:   make([]string, 0),
+			Grants:        make([]statement, 0),
+			Revokes:       make([]statement, 0),
+		}
+
+		grantPermissions, usersToAdd := getDiff(loadedConfig[alias], lockedConfigs[alias], true)
+		revokePermission, usersToRemove := getDiff(lockedConfigs[alias], loadedConfig[alias], false)
+
+		for _, username := range usersToAdd {
+			if username != "" {
+				databasePlan.UsersToCreate = append(databasePlan.UsersToCreate, username)
+			}
+		}
+
+		for _, username := range usersToRemove {
+			if username != "" {
+				databasePlan.UsersToDrop = append(databasePlan.UsersToDrop, username)
+			}
+		}
+
+		sort.Strings(databasePlan.UsersToCreate)
+		sort.Strings(databasePlan.UsersToDrop)
+
+		for _, username := range sortedUsernames(grantPermissions) {
+			databasePlan.Grants = append(databasePlan.Grants, builtQueriesForUser(databaseSecret, username, grantPermissions[username], true)...)
+		}
+
+		for _, username := range sortedUsernames(revokePermission) {
+			databasePlan.Revokes = append(databasePlan.Revokes, builtQueriesForUser(databaseSecret, username, revokePermission[username], false)...)
+		}
+
+		plan.Databases = append(plan.Databases, databasePlan)
+	}
+
+	return &plan, nil
+}
+
+func sortedUsernames(diff map[string]map[string]map[string][]string) []string {
+	usernames := make([]string, 0)
+	for username := range diff {
+		usernames = append(usernames, username)
+	}
+
+	sort.Strings(usernames)
+
+	return usernames
+}
+
+func (p *Plan) IsEmpty() bool {
+	for _, databasePlan := range p.Databases {
+		if !databasePlan.IsEmpty() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *DatabasePlan) IsEmpty() bool {
+	return len(p.UsersToCreate) == 0 && len(p.UsersToDrop) == 0 && len(p.Grants) == 0 && len(p.Revokes) == 0
+}
+
+// Print prints the statements of the plan grouped by database and user
+func (p *Plan) Print() {
+	logger.Info("Proposed changes for %s", logger.Bold(p.AccessConfig))
+	for _, databasePlan := range p.Databases {
+		if databasePlan.IsEmpty() {
+			continue
+		}
+
+		databasePlan.Print()
+	}
+}
+
+// Print prints the statements of the database plan grouped by user
+func (p *DatabasePlan) Print() {
+	usersMap := make(map[string]bool)
+	usersToCreate := make(map[string]bool)
+	usersToDrop := make(map[string]bool)
+	grants := make(map[string][]statement)
+	revokes := make(map[string][]statement)
+
+	for _, username := range p.UsersToCreate {
+		usersMap[username] = true
+		usersToCreate[username] = true
+	}
+
+	for _, username := range p.UsersToDrop {
+		usersMap[username] = true
+		usersToDrop[username] = true
+	}
+
+	for _, stmt := range p.Grants {
+		usersMap[stmt.Username] = true
+		grants[stmt.Username] = append(grants[stmt.Username], stmt)
+	}
+
+	for _, stmt := range p.Revokes {
+		usersMap[stmt.Username] = true
+		revokes[stmt.Username] = append(revokes[stmt.Username], stmt)
+	}
+
+	usernames := make([]string, 0)
+	for username := range usersMap {
+		usernames = append(usernames, username)
+	}
+
+	sort.Strings(usernames)
+
+	secret := Database{Engine: p.Engine, DBName: p.DBName}
+	e := getEngine(secret)
+
+	fmt.Println("|-----------------------------------------------------")
+	fmt.Println(fmt.Sprintf("| Database: %s (%s)", logger.Bold(p.Alias), p.DBName))
+	fmt.Println("|-----------------------------------------------------")
+	for _, username := range usernames {
+		fmt.Println("|", logger.Bold(username))
+
+		if usersToCreate[username] {
+			for _, query := range e.createUserQueries(secret, username, "<generated>") {
+				fmt.Println(logger.Green(fmt.Sprintf("|  + %s;", query)))
+			}
+		}
+
+		if usersToDrop[username] {
+			for i, query := range e.dropUserQueries(secret, username) {
+				fmt.Print(logger.Red(fmt.Sprintf("|  - %s;", query)))
+				if i == 0 {
+					fmt.Print(logger.Bold("    <------- This user and all its grants will be removed"))
+				}
+				fmt.Println()
+			}
+		}
+
+		for _, stmt := range grants[username] {
+			fmt.Print(logger.Green(fmt.Sprintf("|  + %s;", stmt.Query)))
+			printCriticalTableMarker(stmt)
+		}
+
+		for _, stmt := range revokes[username] {
+			fmt.Print(logger.Red(fmt.Sprintf("|  - %s;", stmt.Query)))
+			printCriticalTableMarker(stmt)
+		}
+	}
+	fmt.Println("|-----------------------------------------------------")
+
+	logger.Info(
+		"Plan for %s: %d user(s) to create, %d user(s) to drop, %d grant(s), %d revoke(s)",
+		logger.Bold(p.Alias),
+		len(p.UsersToCreate),
+		len(p.UsersToDrop),
+		len(p.Grants),
+		len(p.Revokes),
+	)
+}
+
+func printCriticalTableMarker(stmt statement) {
+	if _, ok := CriticalTables[stmt.Table]; ok {
+		fmt.Println(logger.Red(logger.Bold("    <------- critical table")))
+		return
+	}
+
+	fmt.Println()
+}
+
+// CreatePlan prints the changes required for the access config and saves them to planFile
+func CreatePlan(ctx context.Context, cfg aws.Config, accessType, planFile string) error {
+	accessConfig, err := getAccessConfig(accessType)
+	if err != nil {
+		return err
+	}
+
+	plan, err := newPlan(ctx, cfg, accessConfig)
+	if err != nil {
+		return err
+	}
+
+	if plan.IsEmpty() {
+		logger.Info("Nothing to do")
+		return nil
+	}
+
+	plan.Print()
+
+	planBytes, err := json.MarshalIndent(plan, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(planFile, planBytes, 0600)
+	if err != nil {
+		return err
+	}
+
+	logger.Success("Saved plan to %s. Run %s to apply it.", logger.Underline(planFile), logger.Bold("onyx rds apply "+planFile))
+
+	return nil
+}
+
+// ApplyPlan applies the plan saved in planFile if the access config and its lock are unchanged since and
+// its statements match the ones they produce
+func ApplyPlan(ctx context.Context, cfg aws.Config, planFile string) error {
+	planBytes, err := os.ReadFile(planFile)
+	if err != nil {
+		return err
+	}
+
+	var plan Plan
+	err = json.Unmarshal(planBytes, &plan)
+	if err != nil {
+		return err
+	}
+
+	configData, _, _, err := loadAccessConfig(plan.AccessConfig)
+	if err != nil {
+		return err
+	}
+
+	if utils.GetSHA512Checksum([]byte(configData)) != plan.ConfigChecksum {
+		return fmt.Errorf("%s has changed since the plan was created, please create a new plan", logger.Underline(plan.AccessConfig))
+	}
+
+	_, lockChecksum, err := loadConfigLock(plan.AccessConfig)
+	if err != nil {
+		return err
+	}
+
+	if lockChecksum != plan.LockChecksum {
+		return fmt.Errorf("%s has changed since the plan was created, please create a new plan", logger.Underline(plan.AccessConfig+".lock"))
+	}
+
+	if plan.IsEmpty() {
+		logger.Info("Nothing to do")
+		return nil
+	}
+
+	// the statements are run as the master user, so they are generated again from the access config and its lock
+	// instead of trusting the ones in the plan file
+	expectedPlan, err := newPlan(ctx, cfg, plan.AccessConfig)
+	if err != nil {
+		return err
+	}
+
+	planDatabases, err := json.Marshal(plan.Databases)
+	if err != nil {
+		return err
+	}
+
+	expectedDatabases, err := json.Marshal(expectedPlan.Databases)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(planDatabases, expectedDatabases) {
+		return fmt.Errorf("the statements of %s do not match %s, please create a new plan", logger.Underline(planFile), logger.Underline(plan.AccessConfig))
+	}
+
+	return applyPlan(ctx, cfg, expectedPlan)
+}
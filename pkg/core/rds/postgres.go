@@ -0,0 +1,263 @@
+package rds
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresEngine manages roles on Postgres and Timescale. Tables in the access config
+// are either `table`, resolved in the public schema, or `schema.table`.
+type postgresEngine struct{}
+
+const postgresDefaultSchema = "public"
+
+// postgresTablePrivileges and postgresColumnPrivileges are what ALL grants on a table and on columns
+var (
+	postgresTablePrivileges  = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"}
+	postgresColumnPrivileges = []string{"SELECT", "INSERT", "UPDATE", "REFERENCES"}
+)
+
+func (e postgresEngine) open(secret Database) (*sql.DB, error) {
+	port := secret.Port
+	if port == 0 {
+		port = 5432
+	}
+
+	dbname := secret.DBName
+	if dbname == "" {
+		dbname = "postgres"
+	}
+
+	dsn := fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=require connect_timeout=10",
+		e.quoteDSNValue(secret.Host),
+		port,
+		e.quoteDSNValue(secret.Username),
+		e.quoteDSNValue(secret.Password),
+		e.quoteDSNValue(dbname),
+	)
+
+	return sql.Open("postgres", dsn)
+}
+
+func (e postgresEngine) createUserQueries(secret Database, username, password string) []string {
+	queries := []string{
+		fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s", e.quoteIdentifier(username), e.quoteString(password)),
+	}
+
+	if secret.DBName != "" {
+		queries = append(queries, fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", e.quoteIdentifier(secret.DBName), e.quoteIdentifier(username)))
+	}
+
+	return queries
+}
+
+func (e postgresEngine) dropUserQueries(secret Database, username string) []string {
+	// objects the role owns are handed over to the master user first, as DROP OWNED would
+	// drop them along with revoking the privileges of the role, without which it can not be dropped
+	return []string{
+		fmt.Sprintf("REASSIGN OWNED BY %s TO %s", e.quoteIdentifier(username), e.quoteIdentifier(secret.Username)),
+		fmt.Sprintf("DROP OWNED BY %s", e.quoteIdentifier(username)),
+		fmt.Sprintf("DROP ROLE %s", e.quoteIdentifier(username)),
+	}
+}
+
+func (e postgresEngine) changePasswordQuery(username, password string) string {
+	return fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s", e.quoteIdentifier(username), e.quoteString(password))
+}
+
+func (e postgresEngine) schemaQueries(username string, tableNames []string) []string {
+	schemas := make(map[string]bool)
+	for _, tableName := range tableNames {
+		schema, _ := e.splitTableName(tableName)
+		schemas[schema] = true
+	}
+
+	queries := make([]string, 0)
+	for schema := range schemas {
+		queries = append(queries, fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", e.quoteIdentifier(schema), e.quoteIdentifier(username)))
+	}
+
+	sort.Strings(queries)
+
+	return queries
+}
+
+func (e postgresEngine) grantQuery(secret Database, username, tableName, grant string, columns []string, isGrant bool) string {
+	permission := "GRANT"
+	permissionHelper := "TO"
+	if !isGrant {
+		permission = "REVOKE"
+		permissionHelper = "FROM"
+	}
+
+	schema, table := e.splitTableName(tableName)
+
+	target := fmt.Sprintf("TABLE %s.%s", e.quoteIdentifier(schema), e.quoteIdentifier(table))
+	if table == "*" {
+		target = fmt.Sprintf("ALL TABLES IN SCHEMA %s", e.quoteIdentifier(schema))
+	}
+
+	if len(columns) == 1 && columns[0] == "*" {
+		return fmt.Sprintf("%s %s ON %s %s %s", permission, grant, target, permissionHelper, e.quoteIdentifier(username))
+	}
+
+	quotedColumns := make([]string, 0)
+	for _, column := range columns {
+		quotedColumns = append(quotedColumns, e.quoteIdentifier(column))
+	}
+
+	return fmt.Sprintf("%s %s (%s) ON %s %s %s", permission, grant, strings.Join(quotedColumns, ", "), target, permissionHelper, e.quoteIdentifier(username))
+}
+
+func (postgresEngine) splitTableName(tableName string) (string, string) {
+	parts := strings.SplitN(tableName, ".", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+
+	return postgresDefaultSchema, tableName
+}
+
+// quoteIdentifier quotes role, schema, table and column names
+func (postgresEngine) quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteString quotes passwords used as string literals
+func (postgresEngine) quoteString(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func (postgresEngine) quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// expandGrants lists schema.* as every table of the schema, as grants on ALL TABLES IN SCHEMA are listed
+// per table, and ALL as its privileges
+func (e postgresEngine) expandGrants(ctx context.Context, db *sql.DB, grants map[string]map[string][]string) (map[string]map[string][]string, error) {
+	schemaTables := make(map[string][]string)
+
+	expanded := make(map[string]map[string][]string)
+	for tableName, tableGrants := range grants {
+		tableNames := []string{tableName}
+
+		schema, table := e.splitTableName(tableName)
+		if table == "*" {
+			if _, ok := schemaTables[schema]; !ok {
+				tables, err := e.readSchemaTables(ctx, db, schema)
+				if err != nil {
+					return nil, err
+				}
+
+				schemaTables[schema] = tables
+			}
+
+			tableNames = schemaTables[schema]
+		}
+
+		for grant, columns := range tableGrants {
+			privileges := []string{grant}
+			if isAllPrivileges(grant) {
+				privileges = postgresTablePrivileges
+				if len(columns) != 1 || columns[0] != "*" {
+					privileges = postgresColumnPrivileges
+				}
+			}
+
+			for _, name := range tableNames {
+				for _, privilege := range privileges {
+					for _, column := range columns {
+						addGrant(expanded, name, privilege, column)
+					}
+				}
+			}
+		}
+	}
+
+	return expanded, nil
+}
+
+// readSchemaTables returns the tables, views and foreign tables of the schema, named like readGrants names them
+func (postgresEngine) readSchemaTables(ctx context.Context, db *sql.DB, schema string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT c.relname FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'f')", schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make([]string, 0)
+	for rows.Next() {
+		var tableName string
+		if err := rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+
+		if schema != postgresDefaultSchema {
+			tableName = schema + "." + tableName
+		}
+
+		tables = append(tables, tableName)
+	}
+
+	return tables, rows.Err()
+}
+
+func (postgresEngine) readGrants(ctx context.Context, db *sql.DB, secret Database, username string) (map[string]map[string][]string, error) {
+	grants := make(map[string]map[string][]string)
+
+	tableRows, err := db.QueryContext(ctx, "SELECT table_schema, table_name, privilege_type FROM information_schema.role_table_grants WHERE grantee = $1", username)
+	if err != nil {
+		return nil, err
+	}
+	defer tableRows.Close()
+
+	for tableRows.Next() {
+		var schema, tableName, privilege string
+		if err := tableRows.Scan(&schema, &tableName, &privilege); err != nil {
+			return nil, err
+		}
+
+		if schema != postgresDefaultSchema {
+			tableName = schema + "." + tableName
+		}
+
+		addGrant(grants, tableName, privilege, "*")
+	}
+
+	if err := tableRows.Err(); err != nil {
+		return nil, err
+	}
+
+	columnRows, err := db.QueryContext(ctx, "SELECT table_schema, table_name, column_name, privilege_type FROM information_schema.column_privileges WHERE grantee = $1", username)
+	if err != nil {
+		return nil, err
+	}
+	defer columnRows.Close()
+
+	for columnRows.Next() {
+		var schema, tableName, columnName, privilege string
+		if err := columnRows.Scan(&schema, &tableName, &columnName, &privilege); err != nil {
+			return nil, err
+		}
+
+		if schema != postgresDefaultSchema {
+			tableName = schema + "." + tableName
+		}
+
+		// table level grants are listed against every column as well
+		if columns, ok := grants[tableName][strings.ToUpper(privilege)]; ok && len(columns) == 1 && columns[0] == "*" {
+			continue
+		}
+
+		addGrant(grants, tableName, privilege, columnName)
+	}
+
+	return grants, columnRows.Err()
+}
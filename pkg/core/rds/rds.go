@@ -1,11 +1,10 @@
 package rds
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -21,14 +20,54 @@ type Database struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	Host     string `json:"host"`
+	Port     int    `json:"port"`
 	DBName   string `json:"dbname"`
+	Engine   string `json:"engine"`
 }
 
 type Config map[string]map[string]map[string][]string
 
+// AccessConfig maps a database alias to the access config of that database.
+// Each alias maps to its secret via rds_secrets in onyx config.
+type AccessConfig map[string]Config
+
+// allowedGrants are the table privileges of postgres and mysql the access config can grant. Grants are
+// put into the statements as they are, so nothing else is accepted.
+var allowedGrants = map[string]bool{
+	"SELECT":         true,
+	"INSERT":         true,
+	"UPDATE":         true,
+	"DELETE":         true,
+	"TRUNCATE":       true,
+	"REFERENCES":     true,
+	"TRIGGER":        true,
+	"CREATE":         true,
+	"DROP":           true,
+	"ALTER":          true,
+	"INDEX":          true,
+	"CREATE VIEW":    true,
+	"SHOW VIEW":      true,
+	"ALL":            true,
+	"ALL PRIVILEGES": true,
+}
+
+// defaultDatabaseAlias is used for access configs without a top level database alias,
+// which are managed with the rds_secret_name secret
+const defaultDatabaseAlias = "default"
+
+// statement is a single GRANT or REVOKE query along with the access it changes
+type statement struct {
+	Username string   `json:"username"`
+	Table    string   `json:"table"`
+	Grant    string   `json:"grant"`
+	Columns  []string `json:"columns"`
+	Query    string   `json:"query"`
+}
+
 type ConfigLock struct {
-	Checksum     string `json:"checksum"`
-	LockedConfig Config `json:"locked_config"`
+	Checksum        string       `json:"checksum"`
+	LockedConfig    Config       `json:"locked_config,omitempty"`
+	LockedDatabases AccessConfig `json:"locked_databases,omitempty"`
 }
 
 var databaseSecret = Database{}
@@ -44,134 +83,414 @@ func RefreshServicesAccess(ctx context.Context, cfg aws.Config) error {
 }
 
 func refreshAccess(ctx context.Context, cfg aws.Config, accessConfig string) error {
-	configData, err := filesystem.ReadFile(accessConfig)
+	plan, err := newPlan(ctx, cfg, accessConfig)
 	if err != nil {
 		return err
 	}
 
-	var loadedConfig Config
+	if plan.IsEmpty() {
+		logger.Info("Nothing to do")
+		return nil
+	}
+
+	return applyPlan(ctx, cfg, plan)
+}
 
-	err = json.Unmarshal([]byte(configData), &loadedConfig)
+// loadAccessConfig loads the access config keyed by database alias. Access configs
+// without a top level alias are loaded under defaultDatabaseAlias and isAliased is false.
+// Every alias of an aliased config needs its secret in rds_secrets.
+func loadAccessConfig(accessConfig string) (configData string, loadedConfig AccessConfig, isAliased bool, err error) {
+	configData, err = filesystem.ReadFile(accessConfig)
 	if err != nil {
-		return err
+		return "", nil, false, err
 	}
 
-	// Load lock file
-	var configLock ConfigLock
-	if filesystem.FileExists(accessConfig + ".lock") {
-		configLockData, err := filesystem.ReadFile(accessConfig + ".lock")
-		if err != nil {
-			return err
+	var singleConfig Config
+	singleErr := json.Unmarshal([]byte(configData), &singleConfig)
+
+	var aliasedConfig AccessConfig
+	aliasedErr := json.Unmarshal([]byte(configData), &aliasedConfig)
+
+	// users without grants parse as either, such a config is aliased if it names a database of rds_secrets
+	isAliased = aliasedErr == nil && (singleErr != nil || hasSecretAlias(aliasedConfig))
+	if !isAliased {
+		if singleErr != nil {
+			return "", nil, false, singleErr
 		}
 
-		err = json.Unmarshal([]byte(configLockData), &configLock)
-		if err != nil {
-			return err
+		loadedConfig = AccessConfig{defaultDatabaseAlias: singleConfig}
+		if err := validateGrants(loadedConfig); err != nil {
+			return "", nil, false, err
 		}
+
+		return configData, loadedConfig, false, nil
 	}
 
-	// Verify checksum to prevent extra work
-	if utils.GetSHA512Checksum([]byte(configData)) == configLock.Checksum {
-		logger.Info("Nothing to do")
-		return nil
+	for alias := range aliasedConfig {
+		if _, ok := config.Config.RDSSecrets[alias]; !ok {
+			return "", nil, false, fmt.Errorf("no rds_secrets entry for database alias %s of %s", alias, accessConfig)
+		}
 	}
 
-	if len(config.Config.RDSSecretName) == 0 {
-		return fmt.Errorf("RDS secret name not specified")
+	if err := validateGrants(aliasedConfig); err != nil {
+		return "", nil, false, err
 	}
 
-	// Load critical table list
-	if filesystem.FileExists(config.Config.RDSCriticalTablesConfig) {
-		var criticalTablesList []string
-		criticalTablesListData, err := filesystem.ReadFile(config.Config.RDSCriticalTablesConfig)
-		if err != nil {
-			return err
+	return configData, aliasedConfig, true, nil
+}
+
+// validateGrants returns an error for the first grant which is not in allowedGrants
+func validateGrants(accessConfig AccessConfig) error {
+	for alias, databaseConfig := range accessConfig {
+		for user, tables := range databaseConfig {
+			for table, grants := range tables {
+				for grant := range grants {
+					if !allowedGrants[strings.ToUpper(grant)] {
+						return fmt.Errorf("invalid grant %q on %s for %s of %s", grant, table, user, alias)
+					}
+				}
+			}
 		}
+	}
 
-		err = json.Unmarshal([]byte(criticalTablesListData), &criticalTablesList)
-		if err != nil {
-			return err
+	return nil
+}
+
+func hasSecretAlias(accessConfig AccessConfig) bool {
+	for alias := range accessConfig {
+		if _, ok := config.Config.RDSSecrets[alias]; ok {
+			return true
 		}
+	}
+
+	return false
+}
+
+// loadConfigLock returns the lock along with the checksum of the lock file
+func loadConfigLock(accessConfig string) (ConfigLock, string, error) {
+	var configLock ConfigLock
+	if !filesystem.FileExists(accessConfig + ".lock") {
+		return configLock, "", nil
+	}
+
+	configLockData, err := filesystem.ReadFile(accessConfig + ".lock")
+	if err != nil {
+		return configLock, "", err
+	}
 
-		for _, table := range criticalTablesList {
-			CriticalTables[table] = true
+	err = json.Unmarshal([]byte(configLockData), &configLock)
+	if err != nil {
+		return configLock, "", err
+	}
+
+	// revokes are built from the lock
+	if err := validateGrants(AccessConfig{defaultDatabaseAlias: configLock.LockedConfig}); err != nil {
+		return configLock, "", err
+	}
+
+	if err := validateGrants(configLock.LockedDatabases); err != nil {
+		return configLock, "", err
+	}
+
+	return configLock, utils.GetSHA512Checksum([]byte(configLockData)), nil
+}
+
+// getLocked returns the locked config keyed by database alias
+func (c *ConfigLock) getLocked(isAliased bool) AccessConfig {
+	if isAliased {
+		if c.LockedDatabases == nil {
+			return make(AccessConfig)
 		}
+
+		return c.LockedDatabases
+	}
+
+	return AccessConfig{defaultDatabaseAlias: c.LockedConfig}
+}
+
+func (c *ConfigLock) setLocked(locked AccessConfig, isAliased bool) {
+	if isAliased {
+		c.LockedConfig = nil
+		c.LockedDatabases = locked
+		return
 	}
 
-	secretString := secretsmanager.GetSecret(ctx, cfg, config.Config.RDSSecretName)
+	c.LockedConfig = locked[defaultDatabaseAlias]
+	c.LockedDatabases = nil
+}
+
+func marshalAccessConfig(accessConfig AccessConfig, isAliased bool) ([]byte, error) {
+	if isAliased {
+		return json.MarshalIndent(accessConfig, "", "    ")
+	}
+
+	return json.MarshalIndent(accessConfig[defaultDatabaseAlias], "", "    ")
+}
+
+func loadCriticalTables() error {
+	if !filesystem.FileExists(config.Config.RDSCriticalTablesConfig) {
+		return nil
+	}
 
-	err = json.Unmarshal([]byte(secretString), &databaseSecret)
+	var criticalTablesList []string
+	criticalTablesListData, err := filesystem.ReadFile(config.Config.RDSCriticalTablesConfig)
 	if err != nil {
 		return err
 	}
 
-	grantPermissions, usersToAdd := getDiff(loadedConfig, configLock.LockedConfig, true)
-	err = createUsers(ctx, cfg, usersToAdd)
+	err = json.Unmarshal([]byte(criticalTablesListData), &criticalTablesList)
 	if err != nil {
 		return err
 	}
 
-	revokePermission, usersToRemove := getDiff(configLock.LockedConfig, loadedConfig, false)
-	err = dropUsers(ctx, cfg, usersToRemove)
+	for _, table := range criticalTablesList {
+		CriticalTables[table] = true
+	}
+
+	return nil
+}
+
+func getSecretName(alias string) string {
+	if alias == defaultDatabaseAlias {
+		return config.Config.RDSSecretName
+	}
+
+	return config.Config.RDSSecrets[alias]
+}
+
+// useDatabase closes any open connection and loads the credentials of the database alias
+func useDatabase(ctx context.Context, cfg aws.Config, alias string) error {
+	disconnect()
+
+	secretName := getSecretName(alias)
+	if len(secretName) == 0 {
+		return fmt.Errorf("RDS secret name not specified for %s", alias)
+	}
+
+	databaseSecret = Database{}
+	secretString := secretsmanager.GetSecret(ctx, cfg, secretName)
+
+	return json.Unmarshal([]byte(secretString), &databaseSecret)
+}
+
+func applyPlan(ctx context.Context, cfg aws.Config, plan *Plan) error {
+	_, loadedConfig, isAliased, err := loadAccessConfig(plan.AccessConfig)
+	if err != nil {
+		return err
+	}
+
+	configLock, _, err := loadConfigLock(plan.AccessConfig)
 	if err != nil {
 		return err
 	}
 
-	run(ctx, cfg, grantPermissions, true, databaseSecret)  // grant
-	run(ctx, cfg, revokePermission, false, databaseSecret) // revoke
+	defer disconnect()
 
-	loadedConfigBytes, err := json.MarshalIndent(loadedConfig, "", "    ")
+	lockedConfigs := configLock.getLocked(isAliased)
+	failures := 0
+	reports := make([]string, 0)
+
+	for _, databasePlan := range plan.Databases {
+		if databasePlan.IsEmpty() {
+			continue
+		}
+
+		logger.Info("Applying changes on %s", logger.Bold(databasePlan.Alias))
+
+		lockedConfig := copyConfig(lockedConfigs[databasePlan.Alias])
+		databaseFailures := 0
+
+		if err := useDatabase(ctx, cfg, databasePlan.Alias); err != nil {
+			logger.Error("Unable to load credentials for %s. Error: %s", databasePlan.Alias, err.Error())
+			failures++
+			reports = append(reports, fmt.Sprintf("%s: %s", logger.Bold(databasePlan.Alias), logger.Red("unable to load credentials")))
+			continue
+		}
+
+		createdUsers := createUsers(ctx, cfg, databasePlan.Alias, databasePlan.UsersToCreate)
+		for _, username := range createdUsers {
+			if _, ok := lockedConfig[username]; !ok {
+				lockedConfig[username] = make(map[string]map[string][]string)
+			}
+		}
+		databaseFailures += len(databasePlan.UsersToCreate) - len(createdUsers)
+
+		droppedUsers := dropUsers(ctx, cfg, databasePlan.UsersToDrop)
+		for _, username := range droppedUsers {
+			delete(lockedConfig, username)
+		}
+		databaseFailures += len(databasePlan.UsersToDrop) - len(droppedUsers)
+
+		grantFailures := run(ctx, cfg, lockedConfig, databasePlan.Grants, true)    // grant
+		revokeFailures := run(ctx, cfg, lockedConfig, databasePlan.Revokes, false) // revoke
+		databaseFailures += grantFailures + revokeFailures
+
+		lockedConfigs[databasePlan.Alias] = lockedConfig
+		failures += databaseFailures
+
+		report := fmt.Sprintf(
+			"%s: created %d, dropped %d, granted %d, revoked %d",
+			logger.Bold(databasePlan.Alias),
+			len(createdUsers),
+			len(droppedUsers),
+			len(databasePlan.Grants)-grantFailures,
+			len(databasePlan.Revokes)-revokeFailures,
+		)
+		if databaseFailures > 0 {
+			report += ", " + logger.Red(fmt.Sprintf("failed %d", databaseFailures))
+		}
+
+		reports = append(reports, report)
+	}
+
+	for _, report := range reports {
+		logger.Info(report)
+	}
+
+	loadedConfigBytes, err := marshalAccessConfig(loadedConfig, isAliased)
 	if err != nil {
 		logger.Error("Unable to update config file")
 		return err
 	}
 
-	filesystem.CreateFileWithData(accessConfig, string(loadedConfigBytes))
+	filesystem.CreateFileWithData(plan.AccessConfig, string(loadedConfigBytes))
 
-	configLock.LockedConfig = loadedConfig
+	configLock.setLocked(loadedConfig, isAliased)
 	configLock.Checksum = utils.GetSHA512Checksum(loadedConfigBytes)
 
+	if failures > 0 {
+		logger.Warn("%d queries failed, only the applied changes will be locked. Rerun to retry the failed ones.", failures)
+
+		lockedConfigBytes, err := marshalAccessConfig(lockedConfigs, isAliased)
+		if err != nil {
+			logger.Error("Unable to update config file")
+			return err
+		}
+
+		configLock.setLocked(lockedConfigs, isAliased)
+		configLock.Checksum = utils.GetSHA512Checksum(lockedConfigBytes)
+	}
+
 	loadedConfigLockBytes, err := json.MarshalIndent(configLock, "", "    ")
 	if err != nil {
 		logger.Error("Unable to update config file")
 		return err
 	}
 
-	return filesystem.CreateFileWithData(accessConfig+".lock", string(loadedConfigLockBytes))
+	return filesystem.CreateFileWithData(plan.AccessConfig+".lock", string(loadedConfigLockBytes))
 }
 
+// run executes the statements one by one, records the applied ones in lockedConfig
+// and returns the number of failed statements
 func run(
 	ctx context.Context,
 	cfg aws.Config,
-	diff map[string]map[string]map[string][]string,
+	lockedConfig Config,
+	statements []statement,
 	isGrant bool,
-	secret Database,
-) {
+) int {
 	permission := "GRANT"
 	if !isGrant {
 		permission = "REVOKE"
 	}
 
-	for username, accessMap := range diff {
-		queries := builtQueriesForUser(secret.DBName, username, accessMap, isGrant)
-		if len(queries) == 0 {
-			logger.Success("Nothing to do for %s", username)
+	failures := 0
+	notified := make(map[string]bool)
+	lastUsername := ""
+	for _, stmt := range statements {
+		if stmt.Username != lastUsername {
+			logger.Info("(%s) Running for %s", permission, stmt.Username)
+			lastUsername = stmt.Username
+		}
+
+		if _, ok := lockedConfig[stmt.Username]; isGrant && !ok {
+			// grants for users which could not be created are bound to fail
+			logger.Error("  %s; user %s does not exist", stmt.Query, stmt.Username)
+			failures++
 			continue
 		}
 
-		logger.Info("(%s) Running for %s", permission, username)
+		if _, ok := CriticalTables[stmt.Table]; ok && isGrant && !notified[stmt.Username+"/"+stmt.Table] {
+			notified[stmt.Username+"/"+stmt.Table] = true
+			logger.Warn("%s is being granted %s access to %s", stmt.Username, stmt.Grant, stmt.Table)
+			notifier.Notify(
+				notifier.SeverityCritical,
+				fmt.Sprintf(":bangbang: %s is being granted %s access to %s", stmt.Username, stmt.Grant, stmt.Table),
+			)
+		}
 
-		_, stderr, err := runQuery(ctx, cfg, strings.Join(queries, ";"))
+		err := runQuery(ctx, cfg, stmt.Query)
 		if err != nil {
-			logger.Error("  %s; \n%s", strings.Join(queries, ";"), stderr)
+			logger.Error("  %s; %s", stmt.Query, err.Error())
+			failures++
+			continue
+		}
+
+		logger.Success("  %s;", stmt.Query)
+
+		if stmt.Grant == "" {
+			continue
+		}
 
-			// TODO: what to do with failed queries?
-			// should they not go in access.lock?
-			// how to extract failure query from string of queries
+		if isGrant {
+			lockGrant(lockedConfig, stmt)
 		} else {
-			logger.Success("  %s;", strings.Join(queries, ";"))
+			unlockGrant(lockedConfig, stmt)
 		}
 	}
+
+	return failures
+}
+
+func copyConfig(c Config) Config {
+	copied := make(Config)
+	for username, tables := range c {
+		copied[username] = make(map[string]map[string][]string)
+		for tableName, grants := range tables {
+			copied[username][tableName] = make(map[string][]string)
+			for grant, columns := range grants {
+				copied[username][tableName][grant] = append([]string{}, columns...)
+			}
+		}
+	}
+
+	return copied
+}
+
+func lockGrant(lockedConfig Config, stmt statement) {
+	if _, ok := lockedConfig[stmt.Username]; !ok {
+		lockedConfig[stmt.Username] = make(map[string]map[string][]string)
+	}
+
+	if _, ok := lockedConfig[stmt.Username][stmt.Table]; !ok {
+		lockedConfig[stmt.Username][stmt.Table] = make(map[string][]string)
+	}
+
+	columns := lockedConfig[stmt.Username][stmt.Table][stmt.Grant]
+	lockedConfig[stmt.Username][stmt.Table][stmt.Grant] = append(columns, utils.GetStringAMinusB(stmt.Columns, columns)...)
+}
+
+func unlockGrant(lockedConfig Config, stmt statement) {
+	tables, ok := lockedConfig[stmt.Username]
+	if !ok {
+		return
+	}
+
+	grants, ok := tables[stmt.Table]
+	if !ok {
+		return
+	}
+
+	columns := utils.GetDifferenceBetweenStringArrays(grants[stmt.Grant], stmt.Columns)
+	if len(columns) == 0 {
+		delete(grants, stmt.Grant)
+	} else {
+		grants[stmt.Grant] = columns
+	}
+
+	if len(grants) == 0 {
+		delete(tables, stmt.Table)
+	}
 }
 
 func getDiff(config, configLock Config, isGrant bool) (map[string]map[string]map[string][]string, []string) {
@@ -244,110 +563,108 @@ func getDiff(config, configLock Config, isGrant bool) (map[string]map[string]map
 	return diff, users
 }
 
-func builtQueriesForUser(dbname string, username string, accessMap map[string]map[string][]string, isGrant bool) []string {
-	queries := make([]string, 0)
+func builtQueriesForUser(secret Database, username string, accessMap map[string]map[string][]string, isGrant bool) []statement {
+	statements := make([]statement, 0)
+	e := getEngine(secret)
 
-	permission := "GRANT"
-	permissionHelper := "TO"
-	if !isGrant {
-		permission = "REVOKE"
-		permissionHelper = "FROM"
+	tableNames := make([]string, 0)
+	for tableName := range accessMap {
+		tableNames = append(tableNames, tableName)
 	}
 
-	for tableName, grants := range accessMap {
-		if permission == "GRANT" {
-			if _, ok := CriticalTables[tableName]; ok {
-				logger.Warn("%s is being granted %v access to %s", username, grants, tableName)
-				notifier.Notify(
-					config.Config.SlackHook,
-					fmt.Sprintf(":bangbang: %s is being granted %v access to %s", username, grants, tableName),
-				)
-			}
+	sort.Strings(tableNames)
+
+	if isGrant {
+		// schema level queries only make the tables reachable and are not tracked in the lock
+		for _, query := range e.schemaQueries(username, tableNames) {
+			statements = append(statements, statement{
+				Username: username,
+				Query:    query,
+			})
+		}
+	}
+
+	for _, tableName := range tableNames {
+		grants := make([]string, 0)
+		for grant := range accessMap[tableName] {
+			grants = append(grants, grant)
 		}
 
-		for grant, columns := range grants {
+		sort.Strings(grants)
+
+		for _, grant := range grants {
+			columns := accessMap[tableName][grant]
 			if len(columns) == 0 {
 				logger.Warn("Skipping %s on %s, no columns present", grant, tableName)
 				continue
 			}
 
-			if len(columns) == 1 && columns[0] == "*" {
-				if isGrant {
-					logger.Warn("%s demands %s on all columns %s.%s", username, grant, dbname, tableName)
-				}
-
-				queries = append(queries, fmt.Sprintf("%s %s on %s.%s %s '%s'@'%%'", permission, grant, dbname, tableName, permissionHelper, username))
-				continue
+			if isGrant && len(columns) == 1 && columns[0] == "*" {
+				logger.Warn("%s demands %s on all columns %s.%s", username, grant, secret.DBName, tableName)
 			}
 
-			queries = append(queries, fmt.Sprintf("%s %s (%s) on %s.%s %s '%s'@'%%'", permission, grant, strings.Join(columns, ", "), dbname, tableName, permissionHelper, username))
+			statements = append(statements, statement{
+				Username: username,
+				Table:    tableName,
+				Grant:    grant,
+				Columns:  columns,
+				Query:    e.grantQuery(secret, username, tableName, grant, columns, isGrant),
+			})
 		}
 	}
 
-	return queries
+	return statements
 }
 
-func runQuery(ctx context.Context, cfg aws.Config, query string) (string, string, error) {
-	if databaseSecret.Host == "" {
-		logger.Info("Fetching DB credentials")
-
-		secretString := secretsmanager.GetSecret(ctx, cfg, config.Config.RDSSecretName)
-		err := json.Unmarshal([]byte(secretString), &databaseSecret)
-		if err != nil {
-			return "", "", err
-		}
-	}
-
-	c := fmt.Sprintf(
-		"mysql -h %s -u %s -p'%s' -e \"%s;\"",
-		databaseSecret.Host,
-		databaseSecret.Username,
-		databaseSecret.Password,
-		query,
-	)
-
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd := exec.Command("bash", "-c", c)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	return stdout.String(), stderr.String(), cmd.Run()
-}
-
-func createUsers(ctx context.Context, cfg aws.Config, usernames []string) error {
+// createUsers creates the given users and returns the ones successfully created
+func createUsers(ctx context.Context, cfg aws.Config, alias string, usernames []string) []string {
+	created := make([]string, 0)
 	for _, username := range usernames {
 		if username == "" {
 			continue
 		}
 
 		newPassword := utils.GetRandomStringWithSymbols(40)
-		_, _, err := runQuery(ctx, cfg, fmt.Sprintf("CREATE USER '%s'@'%%' IDENTIFIED BY '%s'", username, newPassword))
+		err := runQueries(ctx, cfg, getEngine(databaseSecret).createUserQueries(databaseSecret, username, newPassword))
 		if err != nil {
-			return err
+			logger.Error("Unable to create user %s. Error: %s", username, err.Error())
+			continue
 		}
 
 		logger.Info("Created user %s", username)
+		created = append(created, username)
 
-		// TODO: send mail to user with db password
+		err = storeUserSecret(ctx, cfg, alias, username, newPassword)
+		if err != nil {
+			logger.Error(
+				"Unable to store password of %s. Error: %s. Reset it with %s",
+				username,
+				err.Error(),
+				logger.Bold(fmt.Sprintf("onyx rds rotate-password %s --database %s", username, alias)),
+			)
+		}
 	}
 
-	return nil
+	return created
 }
 
-func dropUsers(ctx context.Context, cfg aws.Config, usernames []string) error {
+// dropUsers drops the given users and returns the ones successfully dropped
+func dropUsers(ctx context.Context, cfg aws.Config, usernames []string) []string {
+	dropped := make([]string, 0)
 	for _, username := range usernames {
 		if username == "" {
 			continue
 		}
 
-		_, _, err := runQuery(ctx, cfg, fmt.Sprintf("DROP USER '%s'@'%%'", username))
+		err := runQueries(ctx, cfg, getEngine(databaseSecret).dropUserQueries(databaseSecret, username))
 		if err != nil {
-			return err
+			logger.Error("Unable to drop user %s. Error: %s", username, err.Error())
+			continue
 		}
 
 		logger.Warn("Dropped user %s", username)
+		dropped = append(dropped, username)
 	}
 
-	return nil
+	return dropped
 }
@@ -0,0 +1,101 @@
+package sandstorm
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2Lib "github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/mudrex/onyx/pkg/logger"
+)
+
+// captureInstances returns the running ec2 instances tagged Environment=<env>. Instances of autoscaling
+// groups are left out as the group would replace them once stopped.
+func captureInstances(ctx context.Context, cfg aws.Config, env string) ([]string, error) {
+	ec2Handler := ec2Lib.NewFromConfig(cfg)
+
+	paginator := ec2Lib.NewDescribeInstancesPaginator(ec2Handler, &ec2Lib.DescribeInstancesInput{
+		Filters: []ec2Types.Filter{
+			{
+				Name:   aws.String("tag:Environment"),
+				Values: []string{env},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []string{string(ec2Types.InstanceStateNameRunning)},
+			},
+		},
+	})
+
+	instanceIDs := make([]string, 0)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, reservation := range output.Reservations {
+			for _, instance := range reservation.Instances {
+				if isAutoscaled(instance) {
+					continue
+				}
+
+				instanceIDs = append(instanceIDs, aws.ToString(instance.InstanceId))
+			}
+		}
+	}
+
+	return instanceIDs, nil
+}
+
+func isAutoscaled(instance ec2Types.Instance) bool {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == "aws:autoscaling:groupName" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stopInstances stops the ec2 instances, returning the number of instances that failed to stop
+func stopInstances(ctx context.Context, cfg aws.Config, instanceIDs []string) int {
+	ec2Handler := ec2Lib.NewFromConfig(cfg)
+
+	failed := 0
+	for _, instanceID := range instanceIDs {
+		_, err := ec2Handler.StopInstances(ctx, &ec2Lib.StopInstancesInput{
+			InstanceIds: []string{instanceID},
+		})
+		if err != nil {
+			logger.Error("%s -> %s (ec2) | Error: %s", logger.Bold("init"), logger.Red(logger.Underline(instanceID)), err.Error())
+			failed++
+			continue
+		}
+
+		logger.Success("%s -> %s (ec2)", logger.Bold("init"), logger.Underline(instanceID))
+	}
+
+	return failed
+}
+
+// startInstances starts the ec2 instances, returning the number of instances that failed to start
+func startInstances(ctx context.Context, cfg aws.Config, instanceIDs []string) int {
+	ec2Handler := ec2Lib.NewFromConfig(cfg)
+
+	failed := 0
+	for _, instanceID := range instanceIDs {
+		_, err := ec2Handler.StartInstances(ctx, &ec2Lib.StartInstancesInput{
+			InstanceIds: []string{instanceID},
+		})
+		if err != nil {
+			logger.Error("%s -> %s (ec2) | Error: %s", logger.Bold("revert"), logger.Red(logger.Underline(instanceID)), err.Error())
+			failed++
+			continue
+		}
+
+		logger.Success("%s -> %s (ec2)", logger.Bold("revert"), logger.Underline(instanceID))
+	}
+
+	return failed
+}
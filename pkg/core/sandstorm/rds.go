@@ -0,0 +1,178 @@
+package sandstorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	rdsLib "github.com/aws/aws-sdk-go-v2/service/rds"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/mudrex/onyx/pkg/logger"
+)
+
+// maxDatabaseStartTime is how long revert waits for a database to become available
+const maxDatabaseStartTime = 30 * time.Minute
+
+func hasEnvironmentTag(tags []rdsTypes.Tag, env string) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == "Environment" && aws.ToString(tag.Value) == env {
+			return true
+		}
+	}
+
+	return false
+}
+
+// captureDatabases returns the available rds instances and aurora clusters tagged Environment=<env>.
+// Instances of aurora clusters are left out as they are stopped with their cluster.
+func captureDatabases(ctx context.Context, cfg aws.Config, env string) ([]string, []string, error) {
+	rdsHandler := rdsLib.NewFromConfig(cfg)
+
+	dbInstances := make([]string, 0)
+	instancePaginator := rdsLib.NewDescribeDBInstancesPaginator(rdsHandler, &rdsLib.DescribeDBInstancesInput{})
+	for instancePaginator.HasMorePages() {
+		output, err := instancePaginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, dbInstance := range output.DBInstances {
+			if dbInstance.DBClusterIdentifier != nil || aws.ToString(dbInstance.DBInstanceStatus) != "available" {
+				continue
+			}
+
+			if hasEnvironmentTag(dbInstance.TagList, env) {
+				dbInstances = append(dbInstances, aws.ToString(dbInstance.DBInstanceIdentifier))
+			}
+		}
+	}
+
+	dbClusters := make([]string, 0)
+	clusterPaginator := rdsLib.NewDescribeDBClustersPaginator(rdsHandler, &rdsLib.DescribeDBClustersInput{})
+	for clusterPaginator.HasMorePages() {
+		output, err := clusterPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, dbCluster := range output.DBClusters {
+			if aws.ToString(dbCluster.Status) != "available" {
+				continue
+			}
+
+			if hasEnvironmentTag(dbCluster.TagList, env) {
+				dbClusters = append(dbClusters, aws.ToString(dbCluster.DBClusterIdentifier))
+			}
+		}
+	}
+
+	return dbInstances, dbClusters, nil
+}
+
+// stopDatabases stops the rds instances and aurora clusters, returning the number of them that failed to stop
+func stopDatabases(ctx context.Context, cfg aws.Config, dbInstances, dbClusters []string) int {
+	rdsHandler := rdsLib.NewFromConfig(cfg)
+
+	failed := 0
+	for _, dbCluster := range dbClusters {
+		_, err := rdsHandler.StopDBCluster(ctx, &rdsLib.StopDBClusterInput{
+			DBClusterIdentifier: aws.String(dbCluster),
+		})
+		if err != nil {
+			logger.Error("%s -> %s (aurora) | Error: %s", logger.Bold("init"), logger.Red(logger.Underline(dbCluster)), err.Error())
+			failed++
+			continue
+		}
+
+		logger.Success("%s -> %s (aurora)", logger.Bold("init"), logger.Underline(dbCluster))
+	}
+
+	for _, dbInstance := range dbInstances {
+		_, err := rdsHandler.StopDBInstance(ctx, &rdsLib.StopDBInstanceInput{
+			DBInstanceIdentifier: aws.String(dbInstance),
+		})
+		if err != nil {
+			logger.Error("%s -> %s (rds) | Error: %s", logger.Bold("init"), logger.Red(logger.Underline(dbInstance)), err.Error())
+			failed++
+			continue
+		}
+
+		logger.Success("%s -> %s (rds)", logger.Bold("init"), logger.Underline(dbInstance))
+	}
+
+	return failed
+}
+
+// startDatabases starts the rds instances and aurora clusters and waits for all of them to be available,
+// returning the number of them that failed to start. Databases that are not stopped, e.g. when revert is run
+// again, are only waited for.
+func startDatabases(ctx context.Context, cfg aws.Config, dbInstances, dbClusters []string) int {
+	rdsHandler := rdsLib.NewFromConfig(cfg)
+
+	startedClusters := make([]string, 0)
+	for _, dbCluster := range dbClusters {
+		_, err := rdsHandler.StartDBCluster(ctx, &rdsLib.StartDBClusterInput{
+			DBClusterIdentifier: aws.String(dbCluster),
+		})
+
+		var invalidState *rdsTypes.InvalidDBClusterStateFault
+		if err != nil && !errors.As(err, &invalidState) {
+			logger.Error("%s -> %s (aurora) | Error: %s", logger.Bold("revert"), logger.Red(logger.Underline(dbCluster)), err.Error())
+			continue
+		}
+
+		startedClusters = append(startedClusters, dbCluster)
+	}
+
+	startedInstances := make([]string, 0)
+	for _, dbInstance := range dbInstances {
+		_, err := rdsHandler.StartDBInstance(ctx, &rdsLib.StartDBInstanceInput{
+			DBInstanceIdentifier: aws.String(dbInstance),
+		})
+
+		var invalidState *rdsTypes.InvalidDBInstanceStateFault
+		if err != nil && !errors.As(err, &invalidState) {
+			logger.Error("%s -> %s (rds) | Error: %s", logger.Bold("revert"), logger.Red(logger.Underline(dbInstance)), err.Error())
+			continue
+		}
+
+		startedInstances = append(startedInstances, dbInstance)
+	}
+
+	if len(startedClusters)+len(startedInstances) > 0 {
+		logger.Info("Waiting for %d database(s) to be available", len(startedClusters)+len(startedInstances))
+	}
+
+	available := 0
+
+	clusterWaiter := rdsLib.NewDBClusterAvailableWaiter(rdsHandler)
+	for _, dbCluster := range startedClusters {
+		err := clusterWaiter.Wait(ctx, &rdsLib.DescribeDBClustersInput{
+			DBClusterIdentifier: aws.String(dbCluster),
+		}, maxDatabaseStartTime)
+		if err != nil {
+			logger.Error("%s -> %s (aurora) | Error: %s", logger.Bold("revert"), logger.Red(logger.Underline(dbCluster)), err.Error())
+			continue
+		}
+
+		available++
+		logger.Success("%s -> %s (aurora)", logger.Bold("revert"), logger.Underline(dbCluster))
+	}
+
+	instanceWaiter := rdsLib.NewDBInstanceAvailableWaiter(rdsHandler)
+	for _, dbInstance := range startedInstances {
+		err := instanceWaiter.Wait(ctx, &rdsLib.DescribeDBInstancesInput{
+			DBInstanceIdentifier: aws.String(dbInstance),
+		}, maxDatabaseStartTime)
+		if err != nil {
+			logger.Error("%s -> %s (rds) | Error: %s", logger.Bold("revert"), logger.Red(logger.Underline(dbInstance)), err.Error())
+			continue
+		}
+
+		available++
+		logger.Success("%s -> %s (rds)", logger.Bold("revert"), logger.Underline(dbInstance))
+	}
+
+	return len(dbClusters) + len(dbInstances) - available
+}
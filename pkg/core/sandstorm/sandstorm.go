@@ -2,94 +2,524 @@ package sandstorm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/filesystem"
 	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+const (
+	serviceStopPollInterval = 10 * time.Second
+	serviceStopTimeout      = 10 * time.Minute
 )
 
 type Service struct {
-	Name         string
-	ClusterName  string
-	DesiredCount int32
-	MinCount     int32
-	MaxCount     int32
+	Name        string `json:"service"`
+	ClusterName string `json:"cluster"`
+}
+
+func getResourceID(clusterName, serviceName string) string {
+	return "service/" + clusterName + "/" + serviceName
 }
 
-var staging = []Service{}
+// LoadServices returns the services of the environment from sandstorm_config, which maps every
+// environment to its services in the order they are stopped
+func LoadServices(env string) ([]Service, error) {
+	if config.Config.SandstormConfig == "" {
+		return nil, errors.New("sandstorm_config is not set in onyx config")
+	}
+
+	configData, err := filesystem.ReadFile(config.Config.SandstormConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	environments := make(map[string][]Service)
+	if err := json.Unmarshal([]byte(configData), &environments); err != nil {
+		return nil, err
+	}
+
+	services, ok := environments[env]
+	if !ok {
+		return nil, fmt.Errorf("no environment %s in %s", env, config.Config.SandstormConfig)
+	}
 
-var production = []Service{
-	{
-		Name:         "",
-		DesiredCount: 2,
-		MinCount:     2,
-		MaxCount:     5,
-		ClusterName:  "",
-	},
+	for _, service := range services {
+		if service.Name == "" || service.ClusterName == "" {
+			return nil, fmt.Errorf("services of %s need both service and cluster", env)
+		}
+	}
+
+	return services, nil
 }
 
-func Process(ctx context.Context, cfg aws.Config, env, event string) {
+// captureServices returns the current desired count and autoscaling target of the services
+func captureServices(ctx context.Context, cfg aws.Config, services []Service) ([]ServiceState, error) {
 	ecsHandler := ecs.NewFromConfig(cfg)
 	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
 
-	logger.Info("Running sandstorm %s on %s", logger.Bold(event), logger.Bold(env))
+	states := make([]ServiceState, 0)
+	for _, service := range services {
+		output, err := ecsHandler.DescribeServices(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(service.ClusterName),
+			Services: []string{service.Name},
+		})
+		if err != nil {
+			return nil, err
+		}
 
-	var serviceList []Service
-	if env == "staging" {
-		serviceList = staging
-	} else if env == "production" {
-		serviceList = production
-	} else {
-		return
+		if len(output.Services) == 0 {
+			return nil, fmt.Errorf("no service %s found in %s", service.Name, service.ClusterName)
+		}
+
+		state := ServiceState{
+			ClusterName:  service.ClusterName,
+			Name:         service.Name,
+			DesiredCount: output.Services[0].DesiredCount,
+		}
+
+		targets, err := autoscalingHandler.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+			ServiceNamespace:  types.ServiceNamespaceEcs,
+			ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
+			ResourceIds:       []string{getResourceID(service.ClusterName, service.Name)},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(targets.ScalableTargets) > 0 {
+			target := targets.ScalableTargets[0]
+			state.Scalable = true
+			state.MinCount = aws.ToInt32(target.MinCapacity)
+			state.MaxCount = aws.ToInt32(target.MaxCapacity)
+
+			if target.SuspendedState != nil {
+				state.DynamicScalingInSuspended = aws.ToBool(target.SuspendedState.DynamicScalingInSuspended)
+				state.DynamicScalingOutSuspended = aws.ToBool(target.SuspendedState.DynamicScalingOutSuspended)
+				state.ScheduledScalingSuspended = aws.ToBool(target.SuspendedState.ScheduledScalingSuspended)
+			}
+		}
+
+		states = append(states, state)
 	}
 
-	if event == "revert" {
-		serviceList = reverseArray(serviceList)
+	return states, nil
+}
+
+// getStoppedState returns the state sandstorm init puts the service in, with no tasks and scaling out suspended
+func getStoppedState(state ServiceState) ServiceState {
+	stopped := state
+	stopped.DesiredCount = 0
+
+	if stopped.Scalable {
+		stopped.MinCount = 0
+		stopped.DynamicScalingInSuspended = false
+		stopped.DynamicScalingOutSuspended = true
+		stopped.ScheduledScalingSuspended = true
 	}
 
-	for _, service := range serviceList {
-		desiredCount := service.DesiredCount
-		minCount := service.MinCount
-		if event == "init" {
-			minCount = 0
-			desiredCount = 0
-		}
+	return stopped
+}
+
+func (s ServiceState) describe() string {
+	if !s.Scalable {
+		return fmt.Sprintf("desired %d", s.DesiredCount)
+	}
+
+	return fmt.Sprintf(
+		"desired %d, min %d, max %d, suspended in/out/scheduled %t/%t/%t",
+		s.DesiredCount,
+		s.MinCount,
+		s.MaxCount,
+		s.DynamicScalingInSuspended,
+		s.DynamicScalingOutSuspended,
+		s.ScheduledScalingSuspended,
+	)
+}
 
+func printPlan(event string, from, to []ServiceState) {
+	logger.Info("Planned %s changes", logger.Bold(event))
+	for i := range to {
+		fmt.Printf("%s (%s)\n", logger.Underline(to[i].Name), to[i].ClusterName)
+		fmt.Println("  ", logger.Italic(from[i].describe()))
+		fmt.Println("  ", logger.Bold(to[i].describe()))
+	}
+}
+
+func printResourcePlan(action, resource string, ids []string) {
+	logger.Info("Planned %s of %d %s", logger.Bold(action), len(ids), resource)
+	for _, id := range ids {
+		fmt.Println("  ", logger.Underline(id))
+	}
+}
+
+// stepResult is the outcome of stopping or starting one type of resource
+type stepResult struct {
+	Resource string
+	Total    int
+	Failed   int
+}
+
+func (r stepResult) String() string {
+	if r.Failed > 0 {
+		return fmt.Sprintf("%d of %d %s failed", r.Failed, r.Total, r.Resource)
+	}
+
+	return fmt.Sprintf("%d %s", r.Total, r.Resource)
+}
+
+// runStep runs the step for the resources and logs its progress. A skipped step counts every resource as failed.
+func runStep(event, resource string, total int, skip bool, step func() int) stepResult {
+	result := stepResult{Resource: resource, Total: total}
+	if total == 0 {
+		return result
+	}
+
+	if skip {
+		logger.Warn("%s | skipping %d %s", logger.Bold(event), total, resource)
+		result.Failed = total
+		return result
+	}
+
+	logger.Info("%s | %d %s", logger.Bold(event), total, resource)
+	result.Failed = step()
+
+	if result.Failed > 0 {
+		logger.Warn("%s | %s", logger.Bold(event), result.String())
+	} else {
+		logger.Success("%s | %d %s done", logger.Bold(event), total, resource)
+	}
+
+	return result
+}
+
+// applyState sets the autoscaling target and the desired count of the service to the state
+func applyState(ctx context.Context, cfg aws.Config, event string, state ServiceState) error {
+	ecsHandler := ecs.NewFromConfig(cfg)
+	autoscalingHandler := applicationautoscaling.NewFromConfig(cfg)
+
+	if state.Scalable {
 		_, err := autoscalingHandler.RegisterScalableTarget(ctx, &applicationautoscaling.RegisterScalableTargetInput{
-			ResourceId:        aws.String("service/" + service.ClusterName + "/" + service.Name),
+			ResourceId:        aws.String(getResourceID(state.ClusterName, state.Name)),
 			ServiceNamespace:  types.ServiceNamespaceEcs,
-			MinCapacity:       aws.Int32(minCount),
-			MaxCapacity:       aws.Int32(service.MaxCount),
+			MinCapacity:       aws.Int32(state.MinCount),
+			MaxCapacity:       aws.Int32(state.MaxCount),
 			ScalableDimension: types.ScalableDimensionECSServiceDesiredCount,
 			SuspendedState: &types.SuspendedState{
-				DynamicScalingInSuspended:  aws.Bool(false),
-				DynamicScalingOutSuspended: aws.Bool(event == "init"),
-				ScheduledScalingSuspended:  aws.Bool(event == "init"),
+				DynamicScalingInSuspended:  aws.Bool(state.DynamicScalingInSuspended),
+				DynamicScalingOutSuspended: aws.Bool(state.DynamicScalingOutSuspended),
+				ScheduledScalingSuspended:  aws.Bool(state.ScheduledScalingSuspended),
 			},
 		})
 		if err != nil {
-			logger.Error("%s (%d) -> %s (%s) | autoscaling error: %s", logger.Bold(event), desiredCount, logger.Red(logger.Underline(service.Name)), service.ClusterName, err.Error())
-			continue
+			logger.Error("%s (%d) -> %s (%s) | autoscaling error: %s", logger.Bold(event), state.DesiredCount, logger.Red(logger.Underline(state.Name)), state.ClusterName, err.Error())
+			return err
 		}
+	}
 
-		_, err = ecsHandler.UpdateService(ctx, &ecs.UpdateServiceInput{
-			Cluster:      aws.String(service.ClusterName),
-			Service:      aws.String(service.Name),
-			DesiredCount: aws.Int32(desiredCount),
-		})
-		if err != nil {
-			logger.Error("%s (%d) -> %s (%s) | Error: %s", logger.Bold(event), desiredCount, logger.Red(logger.Underline(service.Name)), service.ClusterName, err.Error())
-		} else {
-			logger.Success("%s (%d) -> %s (%s)", logger.Bold(event), desiredCount, logger.Underline(service.Name), service.ClusterName)
+	_, err := ecsHandler.UpdateService(ctx, &ecs.UpdateServiceInput{
+		Cluster:      aws.String(state.ClusterName),
+		Service:      aws.String(state.Name),
+		DesiredCount: aws.Int32(state.DesiredCount),
+	})
+	if err != nil {
+		logger.Error("%s (%d) -> %s (%s) | Error: %s", logger.Bold(event), state.DesiredCount, logger.Red(logger.Underline(state.Name)), state.ClusterName, err.Error())
+		return err
+	}
+
+	logger.Success("%s (%d) -> %s (%s)", logger.Bold(event), state.DesiredCount, logger.Underline(state.Name), state.ClusterName)
+
+	return nil
+}
+
+// waitForServicesToStop waits until the services have no running tasks, so that instances and databases are
+// only stopped once nothing is connected to them, and returns the number of services which did not stop in time
+func waitForServicesToStop(ctx context.Context, cfg aws.Config, states []ServiceState) int {
+	ecsHandler := ecs.NewFromConfig(cfg)
+
+	pending := make(map[string][]string)
+	for _, state := range states {
+		pending[state.ClusterName] = append(pending[state.ClusterName], state.Name)
+	}
+
+	deadline := time.Now().Add(serviceStopTimeout)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return countPending(pending)
+		case <-time.After(serviceStopPollInterval):
+		}
+
+		for clusterName, serviceNames := range pending {
+			running := make([]string, 0)
+
+			// DescribeServices takes at most 10 services at a time
+			for i := 0; i < len(serviceNames); i += 10 {
+				end := i + 10
+				if end > len(serviceNames) {
+					end = len(serviceNames)
+				}
+
+				output, err := ecsHandler.DescribeServices(ctx, &ecs.DescribeServicesInput{
+					Cluster:  aws.String(clusterName),
+					Services: serviceNames[i:end],
+				})
+				if err != nil {
+					logger.Warn("Unable to describe services of %s, retrying", clusterName)
+					running = append(running, serviceNames[i:end]...)
+					continue
+				}
+
+				for _, service := range output.Services {
+					if service.RunningCount > 0 {
+						running = append(running, aws.ToString(service.ServiceName))
+						continue
+					}
+
+					logger.Success("%s -> %s (%s) has no running tasks", logger.Bold("init"), logger.Underline(aws.ToString(service.ServiceName)), clusterName)
+				}
+			}
+
+			if len(running) == 0 {
+				delete(pending, clusterName)
+			} else {
+				pending[clusterName] = running
+			}
+		}
+	}
+
+	for clusterName, serviceNames := range pending {
+		for _, serviceName := range serviceNames {
+			logger.Error("%s -> %s (%s) | still has running tasks", logger.Bold("init"), logger.Red(logger.Underline(serviceName)), clusterName)
+		}
+	}
+
+	return countPending(pending)
+}
+
+func countPending(pending map[string][]string) int {
+	count := 0
+	for _, serviceNames := range pending {
+		count += len(serviceNames)
+	}
+
+	return count
+}
+
+// Process stops the services, ec2 instances, rds instances and aurora clusters of the environment on init, after
+// saving a snapshot of their state, and restores exactly that snapshot on revert. With dryRun only the planned
+// changes are printed.
+func Process(ctx context.Context, cfg aws.Config, env, event string, dryRun bool) error {
+	logger.Info("Running sandstorm %s on %s", logger.Bold(event), logger.Bold(env))
+
+	snapshot, err := loadSnapshot(ctx, cfg, env)
+	if err != nil {
+		return err
+	}
+
+	switch event {
+	case "init":
+		return initEnvironment(ctx, cfg, env, snapshot, dryRun)
+	case "revert":
+		return revertEnvironment(ctx, cfg, env, snapshot, dryRun)
+	}
+
+	return fmt.Errorf("invalid type: %s", event)
+}
+
+func initEnvironment(ctx context.Context, cfg aws.Config, env string, snapshot *Snapshot, dryRun bool) error {
+	// a second init would overwrite the snapshot with the stopped state
+	if snapshot != nil {
+		return fmt.Errorf("%s was already initialised by %s at %s, revert it first", env, snapshot.CreatedBy, snapshot.CreatedAt.Format(time.RFC3339))
+	}
+
+	services, err := LoadServices(env)
+	if err != nil {
+		return err
+	}
+
+	states, err := captureServices(ctx, cfg, services)
+	if err != nil {
+		return err
+	}
+
+	instances, err := captureInstances(ctx, cfg, env)
+	if err != nil {
+		return err
+	}
+
+	dbInstances, dbClusters, err := captureDatabases(ctx, cfg, env)
+	if err != nil {
+		return err
+	}
+
+	// a snapshot of services stopped by something else would be reverted to no tasks
+	for _, state := range states {
+		if state.Scalable && state.MinCount == 0 && state.MaxCount == 0 {
+			return fmt.Errorf("%s (%s) is already stopped with min and max 0, start it before sandstorm init", state.Name, state.ClusterName)
+		}
+	}
+
+	stoppedStates := make([]ServiceState, 0)
+	for _, state := range states {
+		stoppedStates = append(stoppedStates, getStoppedState(state))
+	}
+
+	printPlan("init", states, stoppedStates)
+	printResourcePlan("stop", "ec2 instance(s)", instances)
+	printResourcePlan("stop", "aurora cluster(s)", dbClusters)
+	printResourcePlan("stop", "rds instance(s)", dbInstances)
+
+	if dryRun {
+		return nil
+	}
+
+	snapshot = &Snapshot{
+		Environment: env,
+		CreatedAt:   time.Now().UTC(),
+		CreatedBy:   utils.GetUser(),
+		Services:    states,
+		Instances:   instances,
+		DBInstances: dbInstances,
+		DBClusters:  dbClusters,
+	}
+
+	if err := saveSnapshot(ctx, cfg, snapshot); err != nil {
+		return fmt.Errorf("unable to save the snapshot, nothing was changed: %s", err.Error())
+	}
+
+	logger.Info("Saved snapshot of %s to %s", env, logger.Underline(getSnapshotLocation(env)))
+
+	// services are stopped first, and the instances and databases they use only once all of them have
+	// no running tasks
+	results := make([]stepResult, 0)
+	results = append(results, runStep("init", "service(s)", len(stoppedStates), false, func() int {
+		failed := 0
+		updatedStates := make([]ServiceState, 0)
+		for _, state := range stoppedStates {
+			if applyState(ctx, cfg, "init", state) != nil {
+				failed++
+				continue
+			}
+
+			updatedStates = append(updatedStates, state)
 		}
+
+		return failed + waitForServicesToStop(ctx, cfg, updatedStates)
+	}))
+
+	servicesFailed := results[0].Failed > 0
+
+	results = append(results, runStep("init", "ec2 instance(s)", len(instances), servicesFailed, func() int {
+		return stopInstances(ctx, cfg, instances)
+	}))
+
+	results = append(results, runStep("init", "database(s)", len(dbClusters)+len(dbInstances), servicesFailed, func() int {
+		return stopDatabases(ctx, cfg, dbInstances, dbClusters)
+	}))
+
+	return reportResult(ctx, env, "init", results)
+}
+
+func revertEnvironment(ctx context.Context, cfg aws.Config, env string, snapshot *Snapshot, dryRun bool) error {
+	if snapshot == nil {
+		return fmt.Errorf("no snapshot of %s found at %s, nothing to revert", env, getSnapshotLocation(env))
+	}
+
+	services := make([]Service, 0)
+	for _, state := range snapshot.Services {
+		services = append(services, Service{Name: state.Name, ClusterName: state.ClusterName})
+	}
+
+	currentStates, err := captureServices(ctx, cfg, services)
+	if err != nil {
+		return err
 	}
+
+	printResourcePlan("start", "aurora cluster(s)", snapshot.DBClusters)
+	printResourcePlan("start", "rds instance(s)", snapshot.DBInstances)
+	printResourcePlan("start", "ec2 instance(s)", snapshot.Instances)
+	printPlan("revert", currentStates, snapshot.Services)
+
+	if dryRun {
+		return nil
+	}
+
+	// databases have to be available before the services that use them are started
+	results := make([]stepResult, 0)
+	results = append(results, runStep("revert", "database(s)", len(snapshot.DBClusters)+len(snapshot.DBInstances), false, func() int {
+		return startDatabases(ctx, cfg, snapshot.DBInstances, snapshot.DBClusters)
+	}))
+
+	databasesFailed := results[0].Failed > 0
+
+	results = append(results, runStep("revert", "ec2 instance(s)", len(snapshot.Instances), false, func() int {
+		return startInstances(ctx, cfg, snapshot.Instances)
+	}))
+
+	// services are started in the reverse order they were stopped in
+	results = append(results, runStep("revert", "service(s)", len(snapshot.Services), databasesFailed, func() int {
+		failed := 0
+		for i := len(snapshot.Services) - 1; i >= 0; i-- {
+			if applyState(ctx, cfg, "revert", snapshot.Services[i]) != nil {
+				failed++
+			}
+		}
+
+		return failed
+	}))
+
+	// the snapshot is kept until everything is restored so that revert can be run again
+	if !hasFailures(results) {
+		if err := deleteSnapshot(ctx, cfg, env); err != nil {
+			logger.Warn("Unable to delete the snapshot of %s: %s", env, err.Error())
+		}
+	}
+
+	return reportResult(ctx, env, "revert", results)
+}
+
+func hasFailures(results []stepResult) bool {
+	for _, result := range results {
+		if result.Failed > 0 {
+			return true
+		}
+	}
+
+	return false
 }
 
-func reverseArray(arr []Service) []Service {
-	for i, j := 0, len(arr)-1; i < j; i, j = i+1, j-1 {
-		arr[i], arr[j] = arr[j], arr[i]
+func reportResult(ctx context.Context, env, event string, results []stepResult) error {
+	summaries := make([]string, 0)
+	for _, result := range results {
+		summaries = append(summaries, result.String())
+	}
+
+	summary := strings.Join(summaries, ", ")
+
+	if hasFailures(results) {
+		log := fmt.Sprintf(":bangbang: [sandstorm/%s] *%s* ran %s on %s, %s", event, utils.GetUser(), event, env, summary)
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "sandstorm/" + event, Target: env, Outcome: audit.OutcomeFailure, Message: log})
+
+		return fmt.Errorf("sandstorm %s on %s failed: %s", event, env, summary)
 	}
-	return arr
+
+	log := fmt.Sprintf("[sandstorm/%s] *%s* ran %s on %s for %s", event, utils.GetUser(), event, env, summary)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "sandstorm/" + event, Target: env, Outcome: audit.OutcomeSuccess, Message: log})
+
+	return nil
 }
@@ -0,0 +1,141 @@
+package sandstorm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	s3Lib "github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/filesystem"
+)
+
+// ServiceState is the state of a service before sandstorm init
+type ServiceState struct {
+	ClusterName  string `json:"cluster"`
+	Name         string `json:"service"`
+	DesiredCount int32  `json:"desired_count"`
+	// Scalable is false for services without an autoscaling target, the rest is only set for scalable ones
+	Scalable                   bool  `json:"scalable"`
+	MinCount                   int32 `json:"min_count"`
+	MaxCount                   int32 `json:"max_count"`
+	DynamicScalingInSuspended  bool  `json:"dynamic_scaling_in_suspended"`
+	DynamicScalingOutSuspended bool  `json:"dynamic_scaling_out_suspended"`
+	ScheduledScalingSuspended  bool  `json:"scheduled_scaling_suspended"`
+}
+
+// Snapshot is the state saved by sandstorm init which revert restores
+type Snapshot struct {
+	Environment string         `json:"environment"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CreatedBy   string         `json:"created_by"`
+	Services    []ServiceState `json:"services"`
+	// the ec2 instances, rds instances and aurora clusters that were running and are started on revert
+	Instances   []string `json:"instances"`
+	DBInstances []string `json:"db_instances"`
+	DBClusters  []string `json:"db_clusters"`
+}
+
+// getSnapshotLocation returns the S3 key of the snapshot if sandstorm_state_bucket is set, else the local
+// file relative to the home directory
+func getSnapshotLocation(env string) string {
+	if config.Config.SandstormStateBucket != "" {
+		return fmt.Sprintf("onyx/sandstorm/%s/snapshot.json", env)
+	}
+
+	return fmt.Sprintf(".onyx-sandstorm-%s.json", env)
+}
+
+// loadSnapshot returns the snapshot of the environment, nil if there is none
+func loadSnapshot(ctx context.Context, cfg aws.Config, env string) (*Snapshot, error) {
+	var data []byte
+
+	if config.Config.SandstormStateBucket != "" {
+		s3Handler := s3Lib.NewFromConfig(cfg)
+
+		output, err := s3Handler.GetObject(ctx, &s3Lib.GetObjectInput{
+			Bucket: aws.String(config.Config.SandstormStateBucket),
+			Key:    aws.String(getSnapshotLocation(env)),
+		})
+		if err != nil {
+			var noSuchKey *s3Types.NoSuchKey
+			if errors.As(err, &noSuchKey) {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+		defer output.Body.Close()
+
+		data, err = io.ReadAll(output.Body)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if !filesystem.FileExists(getSnapshotLocation(env)) {
+			return nil, nil
+		}
+
+		fileData, err := filesystem.ReadFile(getSnapshotLocation(env))
+		if err != nil {
+			return nil, err
+		}
+
+		data = []byte(fileData)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("unable to parse the snapshot of %s: %s", env, err.Error())
+	}
+
+	return &snapshot, nil
+}
+
+func saveSnapshot(ctx context.Context, cfg aws.Config, snapshot *Snapshot) error {
+	data, err := json.MarshalIndent(snapshot, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if config.Config.SandstormStateBucket == "" {
+		return filesystem.CreateFileWithData(getSnapshotLocation(snapshot.Environment), string(data))
+	}
+
+	s3Handler := s3Lib.NewFromConfig(cfg)
+
+	_, err = s3Handler.PutObject(ctx, &s3Lib.PutObjectInput{
+		Bucket: aws.String(config.Config.SandstormStateBucket),
+		Key:    aws.String(getSnapshotLocation(snapshot.Environment)),
+		Body:   bytes.NewReader(data),
+	})
+
+	return err
+}
+
+func deleteSnapshot(ctx context.Context, cfg aws.Config, env string) error {
+	if config.Config.SandstormStateBucket == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+
+		return os.Remove(fmt.Sprintf("%s/%s", home, getSnapshotLocation(env)))
+	}
+
+	s3Handler := s3Lib.NewFromConfig(cfg)
+
+	_, err := s3Handler.DeleteObject(ctx, &s3Lib.DeleteObjectInput{
+		Bucket: aws.String(config.Config.SandstormStateBucket),
+		Key:    aws.String(getSnapshotLocation(env)),
+	})
+
+	return err
+}
@@ -0,0 +1,291 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudwatchEventsLib "github.com/aws/aws-sdk-go-v2/service/cloudwatchevents"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchevents/types"
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/core/sandstorm"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+const (
+	KindScaleUp         = "scale-up"
+	KindScaleDown       = "scale-down"
+	KindSandstormInit   = "sandstorm-init"
+	KindSandstormRevert = "sandstorm-revert"
+)
+
+// rulePrefix marks the eventbridge rules managed by onyx
+const rulePrefix = "onyx-"
+
+// targetID is the id of the run command target of every rule
+const targetID = "onyx"
+
+// executionTimeout is how long, in seconds, run command lets onyx run, sandstorm revert waits for every
+// database to be available
+const executionTimeout = "10800"
+
+// getRuleName returns the name of the rule of the kind, sandstorm ones are per environment
+func getRuleName(kind, env string) (string, error) {
+	switch kind {
+	case KindScaleUp, KindScaleDown:
+		return rulePrefix + kind, nil
+	case KindSandstormInit, KindSandstormRevert:
+		if env == "" {
+			return "", fmt.Errorf("%s needs --env", kind)
+		}
+
+		return rulePrefix + kind + "-" + env, nil
+	}
+
+	return "", fmt.Errorf("invalid schedule %s, expected one of %s, %s, %s or %s", kind, KindScaleUp, KindScaleDown, KindSandstormInit, KindSandstormRevert)
+}
+
+// getCommand returns the onyx command the schedule of the kind runs, the same one that is run by hand so that
+// ec2 instances and databases are stopped and started in order and the snapshots are saved
+func getCommand(kind, env string) (string, error) {
+	var command string
+	switch kind {
+	case KindScaleUp, KindScaleDown:
+		if config.Config.ECSScaleUpConfig == "" {
+			return "", errors.New("ecs_scale_up_config is not set in onyx config")
+		}
+
+		// a scheduled scale overwrites the snapshot of the last one, as there is nobody to confirm
+		command = fmt.Sprintf("onyx ecs scale %s --yes", strings.TrimPrefix(kind, "scale-"))
+	case KindSandstormInit, KindSandstormRevert:
+		if _, err := sandstorm.LoadServices(env); err != nil {
+			return "", err
+		}
+
+		command = fmt.Sprintf("onyx sandstorm %s %s", utils.ShellQuote(env), strings.TrimPrefix(kind, "sandstorm-"))
+	}
+
+	if config.Config.ScheduleUser == "" {
+		return command, nil
+	}
+
+	return fmt.Sprintf("sudo -iu %s %s", utils.ShellQuote(config.Config.ScheduleUser), command), nil
+}
+
+// getSchedule wraps a bare cron expression in cron(), leaving rate() and cron() expressions as they are
+func getSchedule(expression string) string {
+	expression = strings.TrimSpace(expression)
+	for _, prefix := range []string{"cron(", "rate("} {
+		if strings.HasPrefix(expression, prefix) {
+			return expression
+		}
+	}
+
+	return "cron(" + expression + ")"
+}
+
+func getDocumentARN() string {
+	return fmt.Sprintf("arn:aws:ssm:%s::document/AWS-RunShellScript", config.GetRegion())
+}
+
+// Add creates or updates an eventbridge rule which runs the onyx command of the kind on schedule_instance_id
+// through ssm run command, so that scale up/down and sandstorm init/revert run on schedule without a laptop.
+// Schedules are in UTC.
+func Add(ctx context.Context, cfg aws.Config, kind, env, expression string) error {
+	ruleName, err := getRuleName(kind, env)
+	if err != nil {
+		return err
+	}
+
+	if config.Config.ScheduleInstanceID == "" || config.Config.ScheduleRoleARN == "" {
+		return errors.New("schedule_instance_id and schedule_role_arn need to be set in onyx config")
+	}
+
+	command, err := getCommand(kind, env)
+	if err != nil {
+		return err
+	}
+
+	input, err := json.Marshal(map[string][]string{
+		"commands":         {command},
+		"executionTimeout": {executionTimeout},
+	})
+	if err != nil {
+		return err
+	}
+
+	schedule := getSchedule(expression)
+	cloudwatchHandler := cloudwatchEventsLib.NewFromConfig(cfg)
+
+	_, err = cloudwatchHandler.PutRule(ctx, &cloudwatchEventsLib.PutRuleInput{
+		Name:               aws.String(ruleName),
+		ScheduleExpression: aws.String(schedule),
+		State:              types.RuleStateEnabled,
+		Description:        aws.String("Runs " + command + ", managed by onyx schedule"),
+	})
+	if err == nil {
+		var output *cloudwatchEventsLib.PutTargetsOutput
+		output, err = cloudwatchHandler.PutTargets(ctx, &cloudwatchEventsLib.PutTargetsInput{
+			Rule: aws.String(ruleName),
+			Targets: []types.Target{
+				{
+					Id:      aws.String(targetID),
+					Arn:     aws.String(getDocumentARN()),
+					RoleArn: aws.String(config.Config.ScheduleRoleARN),
+					Input:   aws.String(string(input)),
+					RunCommandParameters: &types.RunCommandParameters{
+						RunCommandTargets: []types.RunCommandTarget{
+							{Key: aws.String("InstanceIds"), Values: []string{config.Config.ScheduleInstanceID}},
+						},
+					},
+				},
+			},
+		})
+		if err == nil && output.FailedEntryCount > 0 {
+			err = errors.New(aws.ToString(output.FailedEntries[0].ErrorMessage))
+		}
+	}
+
+	if err != nil {
+		log := fmt.Sprintf(":bangbang: [schedule/add] *%s* failed to schedule %s at %s: %s", utils.GetUser(), ruleName, schedule, err.Error())
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "schedule/add", Target: ruleName, Outcome: audit.OutcomeFailure, Message: log})
+
+		return err
+	}
+
+	logger.Success("%s runs %s on %s at %s UTC", logger.Bold(ruleName), logger.Underline(command), config.Config.ScheduleInstanceID, schedule)
+
+	log := fmt.Sprintf("[schedule/add] *%s* scheduled %s to run %s at %s UTC", utils.GetUser(), ruleName, command, schedule)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "schedule/add", Target: ruleName, Outcome: audit.OutcomeSuccess, Message: log})
+
+	return nil
+}
+
+// getRules returns the eventbridge rules managed by onyx
+func getRules(ctx context.Context, cfg aws.Config) ([]types.Rule, error) {
+	cloudwatchHandler := cloudwatchEventsLib.NewFromConfig(cfg)
+
+	rules := make([]types.Rule, 0)
+	input := &cloudwatchEventsLib.ListRulesInput{NamePrefix: aws.String(rulePrefix)}
+	for {
+		output, err := cloudwatchHandler.ListRules(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, output.Rules...)
+
+		if output.NextToken == nil {
+			return rules, nil
+		}
+
+		input.NextToken = output.NextToken
+	}
+}
+
+// getRuleCommand returns the command the run command target of the rule runs
+func getRuleCommand(ctx context.Context, cfg aws.Config, ruleName string) string {
+	cloudwatchHandler := cloudwatchEventsLib.NewFromConfig(cfg)
+
+	output, err := cloudwatchHandler.ListTargetsByRule(ctx, &cloudwatchEventsLib.ListTargetsByRuleInput{
+		Rule: aws.String(ruleName),
+	})
+	if err != nil {
+		return "-"
+	}
+
+	for _, target := range output.Targets {
+		if aws.ToString(target.Id) != targetID {
+			continue
+		}
+
+		var input map[string][]string
+		if json.Unmarshal([]byte(aws.ToString(target.Input)), &input) == nil && len(input["commands"]) > 0 {
+			return strings.Join(input["commands"], "; ")
+		}
+	}
+
+	return "-"
+}
+
+// List prints the schedules managed by onyx
+func List(ctx context.Context, cfg aws.Config) error {
+	rules, err := getRules(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(rules) == 0 {
+		logger.Info("No schedules found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSCHEDULE (UTC)\tSTATE\tCOMMAND\t")
+	for _, rule := range rules {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t\n",
+			aws.ToString(rule.Name),
+			aws.ToString(rule.ScheduleExpression),
+			rule.State,
+			getRuleCommand(ctx, cfg, aws.ToString(rule.Name)),
+		)
+	}
+
+	return w.Flush()
+}
+
+// Remove deletes the rule of the kind along with its target
+func Remove(ctx context.Context, cfg aws.Config, kind, env string) error {
+	ruleName, err := getRuleName(kind, env)
+	if err != nil {
+		return err
+	}
+
+	cloudwatchHandler := cloudwatchEventsLib.NewFromConfig(cfg)
+
+	_, err = cloudwatchHandler.DescribeRule(ctx, &cloudwatchEventsLib.DescribeRuleInput{Name: aws.String(ruleName)})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("no schedule %s found", ruleName)
+		}
+
+		return err
+	}
+
+	_, err = cloudwatchHandler.RemoveTargets(ctx, &cloudwatchEventsLib.RemoveTargetsInput{
+		Rule: aws.String(ruleName),
+		Ids:  []string{targetID},
+	})
+	if err == nil {
+		_, err = cloudwatchHandler.DeleteRule(ctx, &cloudwatchEventsLib.DeleteRuleInput{Name: aws.String(ruleName)})
+	}
+
+	if err != nil {
+		log := fmt.Sprintf(":bangbang: [schedule/remove] *%s* failed to remove %s: %s", utils.GetUser(), ruleName, err.Error())
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: "schedule/remove", Target: ruleName, Outcome: audit.OutcomeFailure, Message: log})
+
+		return err
+	}
+
+	logger.Success("Removed %s", logger.Bold(ruleName))
+
+	log := fmt.Sprintf("[schedule/remove] *%s* removed %s", utils.GetUser(), ruleName)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "schedule/remove", Target: ruleName, Outcome: audit.OutcomeSuccess, Message: log})
+
+	return nil
+}
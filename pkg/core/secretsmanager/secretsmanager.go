@@ -2,9 +2,11 @@ package secretsmanager
 
 import (
 	"context"
+	"errors"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 )
 
 func GetSecret(ctx context.Context, cfg aws.Config, name string) (value string) {
@@ -19,3 +21,43 @@ func GetSecret(ctx context.Context, cfg aws.Config, name string) (value string)
 
 	return aws.ToString(result.SecretString)
 }
+
+// PutSecret creates the secret with the given tags, or stores a new version of its value if it already exists
+func PutSecret(ctx context.Context, cfg aws.Config, name, value string, tags map[string]string) error {
+	svc := secretsmanager.NewFromConfig(cfg)
+
+	secretTags := make([]types.Tag, 0)
+	for key, tagValue := range tags {
+		secretTags = append(secretTags, types.Tag{Key: aws.String(key), Value: aws.String(tagValue)})
+	}
+
+	_, err := svc.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(name),
+		SecretString: aws.String(value),
+		Tags:         secretTags,
+	})
+
+	var existsErr *types.ResourceExistsException
+	if !errors.As(err, &existsErr) {
+		return err
+	}
+
+	_, err = svc.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(name),
+		SecretString: aws.String(value),
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(secretTags) == 0 {
+		return nil
+	}
+
+	_, err = svc.TagResource(ctx, &secretsmanager.TagResourceInput{
+		SecretId: aws.String(name),
+		Tags:     secretTags,
+	})
+
+	return err
+}
@@ -0,0 +1,275 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/auth"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+	cryptoSSH "golang.org/x/crypto/ssh"
+)
+
+// Transfer is the size and sha256 checksum of a copied file
+type Transfer struct {
+	Bytes  int64
+	SHA256 string
+}
+
+func (t Transfer) String() string {
+	return fmt.Sprintf("%d bytes, sha256 %s", t.Bytes, t.SHA256)
+}
+
+// remoteCommand returns command to run on the host, or inside the container if containerID is set
+func remoteCommand(containerID, command string) string {
+	if containerID == "" {
+		return command
+	}
+
+	return fmt.Sprintf("docker exec -i %s sh -c %s", containerID, utils.ShellQuote(command))
+}
+
+func runRemoteCommand(client *cryptoSSH.Client, command string, stdin io.Reader, stdout io.Writer) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = &stderr
+
+	if err := session.Run(command); err != nil {
+		if stderr.Len() > 0 {
+			return fmt.Errorf("%s: %s", err.Error(), strings.TrimSpace(stderr.String()))
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// getRemoteChecksum returns the sha256 checksum of the remote file, empty if sha256sum is unavailable
+func getRemoteChecksum(client *cryptoSSH.Client, containerID, remotePath string) string {
+	var out bytes.Buffer
+	if err := runRemoteCommand(client, remoteCommand(containerID, "sha256sum "+utils.ShellQuote(remotePath)), nil, &out); err != nil {
+		return ""
+	}
+
+	fields := strings.Fields(out.String())
+	if len(fields) == 0 {
+		return ""
+	}
+
+	return fields[0]
+}
+
+// CopyFromRemote copies the remote file of the host given as <user>@<ip>, or of the container on it if
+// containerID is set, to localPath
+func CopyFromRemote(userHost, containerID, remotePath, localPath string) (Transfer, error) {
+	// dial drops root, so localPath is only created once onyx runs as the user
+	client, err := dial(userHost)
+	if err != nil {
+		return Transfer{}, err
+	}
+	defer client.Close()
+
+	var out bytes.Buffer
+	err = runRemoteCommand(client, remoteCommand(containerID, "stat -c %s "+utils.ShellQuote(remotePath)), nil, &out)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("unable to stat %s: %s", remotePath, err.Error())
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("unable to read the size of %s", remotePath)
+	}
+
+	if size > config.GetMaxCopySize() {
+		return Transfer{}, fmt.Errorf("%s is %d bytes, larger than the maximum of %d bytes", remotePath, size, config.GetMaxCopySize())
+	}
+
+	if info, err := os.Stat(localPath); err == nil && info.IsDir() {
+		localPath = filepath.Join(localPath, path.Base(remotePath))
+	}
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return Transfer{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+
+	err = runRemoteCommand(client, remoteCommand(containerID, "cat "+utils.ShellQuote(remotePath)), nil, io.MultiWriter(file, hash, counter))
+	if err != nil {
+		os.Remove(localPath)
+		return Transfer{}, fmt.Errorf("unable to read %s: %s", remotePath, err.Error())
+	}
+
+	transfer := Transfer{Bytes: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+
+	if remoteChecksum := getRemoteChecksum(client, containerID, remotePath); remoteChecksum != "" && remoteChecksum != transfer.SHA256 {
+		os.Remove(localPath)
+		return transfer, fmt.Errorf("checksum mismatch, remote %s, local %s", remoteChecksum, transfer.SHA256)
+	}
+
+	return transfer, nil
+}
+
+// CopyToRemote copies localPath to the remote path of the host given as <user>@<ip>, or of the container
+// on it if containerID is set
+func CopyToRemote(userHost, containerID, localPath, remotePath string) (Transfer, error) {
+	// the binary is setuid root, so localPath is opened as the user running onyx and only files they can read
+	// are copied. The saved uid is left as root for dial to read the private key.
+	if err := syscall.Seteuid(syscall.Getuid()); err != nil {
+		return Transfer{}, err
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return Transfer{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Transfer{}, err
+	}
+
+	if info.IsDir() {
+		return Transfer{}, fmt.Errorf("%s is a directory", localPath)
+	}
+
+	if info.Size() > config.GetMaxCopySize() {
+		return Transfer{}, fmt.Errorf("%s is %d bytes, larger than the maximum of %d bytes", localPath, info.Size(), config.GetMaxCopySize())
+	}
+
+	client, err := dial(userHost)
+	if err != nil {
+		return Transfer{}, err
+	}
+	defer client.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+
+	err = runRemoteCommand(client, remoteCommand(containerID, "cat > "+utils.ShellQuote(remotePath)), io.TeeReader(file, io.MultiWriter(hash, counter)), nil)
+	if err != nil {
+		return Transfer{}, fmt.Errorf("unable to write %s: %s", remotePath, err.Error())
+	}
+
+	transfer := Transfer{Bytes: counter.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+
+	if remoteChecksum := getRemoteChecksum(client, containerID, remotePath); remoteChecksum != "" && remoteChecksum != transfer.SHA256 {
+		return transfer, fmt.Errorf("checksum mismatch, local %s, remote %s", transfer.SHA256, remoteChecksum)
+	}
+
+	return transfer, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// splitRemotePath splits <prefix>:<path>, returning false for local paths
+func splitRemotePath(arg string) (string, string, bool) {
+	i := strings.Index(arg, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+
+	return arg[:i], arg[i+1:], true
+}
+
+// ParseCopyArgs returns the remote prefix and path, the local path and whether the copy is from the
+// remote, for copies given as <prefix>:<path> <local> or <local> <prefix>:<path>
+func ParseCopyArgs(source, destination string) (string, string, string, bool, error) {
+	sourcePrefix, sourcePath, sourceIsRemote := splitRemotePath(source)
+	destinationPrefix, destinationPath, destinationIsRemote := splitRemotePath(destination)
+
+	switch {
+	case sourceIsRemote && !destinationIsRemote:
+		return sourcePrefix, sourcePath, destination, true, nil
+	case !sourceIsRemote && destinationIsRemote:
+		return destinationPrefix, destinationPath, source, false, nil
+	default:
+		return "", "", "", false, fmt.Errorf("exactly one of %s and %s should be remote", source, destination)
+	}
+}
+
+// Copy copies a file between the local machine and a host, given as <user>@<ip>:<path> on either side
+func Copy(ctx context.Context, source, destination string) error {
+	userHost, remotePath, localPath, fromRemote, err := ParseCopyArgs(source, destination)
+	if err != nil {
+		return err
+	}
+
+	_, host, err := splitUserHost(userHost)
+	if err != nil {
+		return err
+	}
+
+	username := utils.GetUser()
+
+	if err := checkPrivateHost(ctx, "ssh/cp", username, host); err != nil {
+		return err
+	}
+
+	isAuthorized, err := auth.CheckUserAccessForHostShell(ctx, username, host)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		log := fmt.Sprintf("[ssh/cp] *%s* is not authorized to copy %s to %s", username, source, destination)
+		audit.Log(ctx, audit.Entry{Command: "ssh/cp", Target: userHost, Outcome: audit.OutcomeDenied, Message: log})
+
+		logger.Error("%s is not authorized to access %s", logger.Underline(username), logger.Bold(userHost))
+		return nil
+	}
+
+	var transfer Transfer
+	if fromRemote {
+		transfer, err = CopyFromRemote(userHost, "", remotePath, localPath)
+	} else {
+		transfer, err = CopyToRemote(userHost, "", localPath, remotePath)
+	}
+
+	if err != nil {
+		log := fmt.Sprintf("[ssh/cp] *%s* failed to copy %s to %s: %s", username, source, destination, err.Error())
+		audit.Log(ctx, audit.Entry{Command: "ssh/cp", Target: userHost, Outcome: audit.OutcomeFailure, Message: log})
+
+		return err
+	}
+
+	log := fmt.Sprintf("[ssh/cp] *%s* copied %s to %s (%s)", username, source, destination, transfer.String())
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ssh/cp", Target: userHost, Outcome: audit.OutcomeSuccess, Message: log})
+
+	logger.Success("Copied %s to %s (%s)", source, destination, transfer.String())
+
+	return nil
+}
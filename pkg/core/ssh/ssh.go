@@ -19,28 +19,36 @@ import (
 
 var accessList = map[string]map[string]int{}
 
+// checkPrivateHost reports and refuses hosts outside of the VPC
+func checkPrivateHost(ctx context.Context, command, username, host string) error {
+	if config.Config.VPCCidr == "" {
+		return nil
+	}
+
+	_, cidr, err := net.ParseCIDR(config.Config.VPCCidr)
+	if err != nil {
+		return err
+	}
+
+	if !cidr.Contains(net.ParseIP(host)) {
+		log := fmt.Sprintf(":bangbang: [%s] *%s* attempted ssh via public ip: _%s_", command, username, host)
+		notifier.Notify(notifier.SeverityCritical, log)
+		audit.Log(ctx, audit.Entry{Command: command, Target: host, Outcome: audit.OutcomeDenied, Message: log})
+
+		return fmt.Errorf("%s is not a private IP. Aborting. %s", logger.Underline(host), logger.Red("This act will be reported"))
+	}
+
+	return nil
+}
+
 func Do(ctx context.Context, userHost string) error {
 	sshUser := strings.Split(userHost, "@")[0]
 	host := strings.Split(userHost, "@")[1]
 
 	username := utils.GetUser()
 
-	if config.Config.VPCCidr != "" {
-		_, cidr, err := net.ParseCIDR(config.Config.VPCCidr)
-		if err != nil {
-			return err
-		}
-
-		if !cidr.Contains(net.ParseIP(host)) {
-			log := fmt.Sprintf(":bangbang: [ssh/do] *%s* attempted ssh via public ip: _%s_", username, host)
-			notifier.Notify(
-				config.Config.SlackHook,
-				log,
-			)
-			audit.Log(ctx, log)
-
-			return fmt.Errorf("%s is not a private IP. Aborting. %s", logger.Underline(host), logger.Red("This act will be reported"))
-		}
+	if err := checkPrivateHost(ctx, "ssh/do", username, host); err != nil {
+		return err
 	}
 
 	isAuthorized, err := auth.CheckUserAccessForHostShell(ctx, username, host)
@@ -68,12 +76,9 @@ func Do(ctx context.Context, userHost string) error {
 	logger.Info("Spawning shell for %s", logger.Underline(userHost))
 
 	log := fmt.Sprintf("[ssh/do] *%s* logged in to _%s_", username, userHost)
-	notifier.Notify(
-		config.Config.SlackHook,
-		log,
-	)
+	notifier.Notify(notifier.SeverityInfo, log)
 
-	audit.Log(ctx, log)
+	audit.Log(ctx, audit.Entry{Command: "ssh/do", Target: userHost, Outcome: audit.OutcomeSuccess, Message: log})
 
 	sshCmdDockerShell := fmt.Sprintf("ssh -t -i %s %s", config.Config.PrivateKey, userHost)
 	out1 := exec.Command("bash", "-c", sshCmdDockerShell)
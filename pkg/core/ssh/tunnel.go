@@ -0,0 +1,223 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mudrex/onyx/pkg/audit"
+	"github.com/mudrex/onyx/pkg/auth"
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+	"github.com/mudrex/onyx/pkg/notifier"
+	"github.com/mudrex/onyx/pkg/utils"
+	cryptoSSH "golang.org/x/crypto/ssh"
+)
+
+// activityWriter records the time of the last write to detect idle tunnels
+type activityWriter struct {
+	w            io.Writer
+	lastActivity *int64
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	atomic.StoreInt64(a.lastActivity, time.Now().UnixNano())
+	return a.w.Write(p)
+}
+
+func splitUserHost(userHost string) (string, string, error) {
+	parts := strings.Split(userHost, "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid host %s, expected <user>@<ip>", userHost)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// dial connects to the host given as <user>@<ip> with the private key of onyx config
+func dial(userHost string) (*cryptoSSH.Client, error) {
+	sshUser, host, err := splitUserHost(userHost)
+	if err != nil {
+		return nil, err
+	}
+
+	// the private key is only readable by root
+	userUID := syscall.Getuid()
+	err = syscall.Setuid(0)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := utils.DialSSH(config.Config.PrivateKey, host, sshUser)
+	syscall.Setuid(userUID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %s", userHost, err.Error())
+	}
+
+	return client, nil
+}
+
+// Forward listens on localPort and forwards every connection to remoteAddress through the jump host, given as
+// <user>@<ip>, until interrupted or no data flows through the tunnel for idleTimeout
+func Forward(ctx context.Context, jumpUserHost string, localPort int, remoteAddress string, idleTimeout time.Duration) error {
+	client, err := dial(jumpUserHost)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	lastActivity := time.Now().UnixNano()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				listener.Close()
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, atomic.LoadInt64(&lastActivity))) > idleTimeout {
+					logger.Warn("No traffic for %s, closing the tunnel", idleTimeout)
+					stop()
+				}
+			}
+		}
+	}()
+
+	logger.Info(
+		"Forwarding %s -> %s via %s. Press Ctrl+C to stop.",
+		logger.Bold(listener.Addr().String()),
+		logger.Bold(remoteAddress),
+		logger.Underline(jumpUserHost),
+	)
+
+	var wg sync.WaitGroup
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+
+			return err
+		}
+
+		atomic.StoreInt64(&lastActivity, time.Now().UnixNano())
+
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			forwardConnection(ctx, client, conn, remoteAddress, &lastActivity)
+		}(conn)
+	}
+
+	wg.Wait()
+	logger.Success("Tunnel closed")
+
+	return nil
+}
+
+// forwardConnection copies data between the local connection and remoteAddress until either side closes
+// or the tunnel is closed
+func forwardConnection(ctx context.Context, client *cryptoSSH.Client, conn net.Conn, remoteAddress string, lastActivity *int64) {
+	defer conn.Close()
+
+	remote, err := client.Dial("tcp", remoteAddress)
+	if err != nil {
+		logger.Error("Unable to connect to %s. Error: %s", remoteAddress, err.Error())
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(&activityWriter{w: remote, lastActivity: lastActivity}, conn)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		io.Copy(&activityWriter{w: conn, lastActivity: lastActivity}, remote)
+		done <- struct{}{}
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Tunnel forwards localPort to remoteAddress, a host:port in the VPC, through the jump host given as
+// <user>@<ip> or the tunnel_host of onyx config
+func Tunnel(ctx context.Context, remoteAddress, jumpUserHost string, localPort int, idleTimeout time.Duration) error {
+	if jumpUserHost == "" {
+		jumpUserHost = config.Config.TunnelHost
+	}
+
+	if jumpUserHost == "" {
+		return errors.New("no host to tunnel through, pass --via or set tunnel_host in onyx config")
+	}
+
+	_, jumpHost, err := splitUserHost(jumpUserHost)
+	if err != nil {
+		return err
+	}
+
+	_, remotePort, err := net.SplitHostPort(remoteAddress)
+	if err != nil {
+		return fmt.Errorf("invalid address %s, expected <host>:<port>", remoteAddress)
+	}
+
+	if localPort == 0 {
+		localPort, err = strconv.Atoi(remotePort)
+		if err != nil {
+			return fmt.Errorf("invalid port %s", remotePort)
+		}
+	}
+
+	username := utils.GetUser()
+
+	if err := checkPrivateHost(ctx, "ssh/tunnel", username, jumpHost); err != nil {
+		return err
+	}
+
+	// the tunnel opens a shell session on the jump host only, the remote address is reached from there
+	isAuthorized, err := auth.CheckUserAccessForHostShell(ctx, username, jumpHost)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		log := fmt.Sprintf("[ssh/tunnel] *%s* is not authorized to tunnel to _%s_ via _%s_", username, remoteAddress, jumpUserHost)
+		audit.Log(ctx, audit.Entry{Command: "ssh/tunnel", Target: remoteAddress, Outcome: audit.OutcomeDenied, Message: log})
+
+		logger.Error("%s is not authorized to access %s", logger.Underline(username), logger.Bold(jumpHost))
+		return nil
+	}
+
+	log := fmt.Sprintf("[ssh/tunnel] *%s* opened a tunnel from port %d to _%s_ via _%s_", username, localPort, remoteAddress, jumpUserHost)
+	notifier.Notify(notifier.SeverityInfo, log)
+	audit.Log(ctx, audit.Entry{Command: "ssh/tunnel", Target: remoteAddress, Outcome: audit.OutcomeSuccess, Message: log})
+
+	return Forward(ctx, jumpUserHost, localPort, remoteAddress, idleTimeout)
+}
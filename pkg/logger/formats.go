@@ -21,3 +21,11 @@ func Green(message interface{}) string {
 func Italic(message string) string {
 	return color.New(color.Italic).Sprint(message)
 }
+
+func Yellow(message interface{}) string {
+	return color.New(color.FgYellow).Sprint(message)
+}
+
+func Cyan(message interface{}) string {
+	return color.New(color.FgCyan).Sprint(message)
+}
@@ -0,0 +1,104 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/logger"
+)
+
+type Severity string
+
+const (
+	// SeverityInfo is for routine events like logins
+	SeverityInfo Severity = "info"
+	// SeverityCritical is for :bangbang: events like public ip ssh attempts and critical table grants
+	SeverityCritical Severity = "critical"
+)
+
+// Notifier sends a message to a single sink
+type Notifier interface {
+	Send(severity Severity, message string) error
+}
+
+type sink struct {
+	notifier   Notifier
+	severities map[Severity]bool
+}
+
+func (s *sink) accepts(severity Severity) bool {
+	return len(s.severities) == 0 || s.severities[severity]
+}
+
+func newNotifier(notifierConfig config.NotifierConfig) (Notifier, error) {
+	switch notifierConfig.Type {
+	case "slack":
+		return &slackNotifier{hook: notifierConfig.URL}, nil
+	case "teams":
+		return &teamsNotifier{hook: notifierConfig.URL}, nil
+	case "webhook":
+		return &webhookNotifier{url: notifierConfig.URL}, nil
+	case "smtp":
+		if notifierConfig.SMTP == nil {
+			return nil, errors.New("smtp notifier requires smtp settings")
+		}
+
+		return &smtpNotifier{config: *notifierConfig.SMTP}, nil
+	case "stdout":
+		return &stdoutNotifier{}, nil
+	}
+
+	return nil, fmt.Errorf("unknown notifier type %s", notifierConfig.Type)
+}
+
+// getSinks returns the sinks from notifiers in onyx config, falling back to slack_hook
+// for all severities, or stdout if neither is set
+func getSinks() []sink {
+	if len(config.Config.Notifiers) == 0 {
+		if config.Config.SlackHook != "" {
+			return []sink{{notifier: &slackNotifier{hook: config.Config.SlackHook}}}
+		}
+
+		return []sink{{notifier: &stdoutNotifier{}}}
+	}
+
+	sinks := make([]sink, 0)
+	for _, notifierConfig := range config.Config.Notifiers {
+		n, err := newNotifier(notifierConfig)
+		if err != nil {
+			logger.Error("Skipping notifier. Error: %s", err.Error())
+			continue
+		}
+
+		severities := make(map[Severity]bool)
+		for _, severity := range notifierConfig.Severities {
+			severities[Severity(strings.ToLower(severity))] = true
+		}
+
+		sinks = append(sinks, sink{notifier: n, severities: severities})
+	}
+
+	return sinks
+}
+
+// Notify sends the message to every sink configured for the severity
+func Notify(severity Severity, message string) error {
+	failed := make([]string, 0)
+	for _, s := range getSinks() {
+		if !s.accepts(severity) {
+			continue
+		}
+
+		if err := s.notifier.Send(severity, message); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to send notification. Error: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
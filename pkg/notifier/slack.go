@@ -3,25 +3,32 @@ package notifier
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
 	"time"
-
-	"github.com/mudrex/onyx/pkg/logger"
 )
 
 type slackRequestBody struct {
 	Text string `json:"text"`
 }
 
-func Notify(hook, message string) error {
-	if hook == "" {
-		logger.Info(message)
-		return nil
+// slackNotifier posts to a slack incoming webhook
+type slackNotifier struct {
+	hook string
+}
+
+func (s *slackNotifier) Send(severity Severity, message string) error {
+	return postJSON(s.hook, slackRequestBody{Text: message})
+}
+
+// postJSON posts body to url and fails on any non 2xx response
+func postJSON(url string, body interface{}) error {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return err
 	}
 
-	slackBody, _ := json.Marshal(slackRequestBody{Text: message})
-	req, err := http.NewRequest(http.MethodPost, hook, bytes.NewBuffer(slackBody))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return err
 	}
@@ -34,10 +41,12 @@ func Notify(hook, message string) error {
 		return err
 	}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.Body)
-	if buf.String() != "ok" {
-		return errors.New("unable to send notification to slack")
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(resp.Body)
+		return fmt.Errorf("%s responded with %d: %s", req.URL.Host, resp.StatusCode, buf.String())
 	}
 
 	return nil
@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"fmt"
+	"net/smtp"
+	"strings"
+
+	"github.com/mudrex/onyx/pkg/config"
+)
+
+// smtpNotifier mails the message to the configured recipients
+type smtpNotifier struct {
+	config config.SMTPConfig
+}
+
+func (s *smtpNotifier) Send(severity Severity, message string) error {
+	port := s.config.Port
+	if port == 0 {
+		port = 587
+	}
+
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	subject := fmt.Sprintf("[onyx/%s] %s", config.Config.Environment, strings.SplitN(message, "\n", 2)[0])
+	if severity == SeverityCritical {
+		subject = "[CRITICAL] " + subject
+	}
+
+	body := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.config.From,
+		strings.Join(s.config.To, ", "),
+		subject,
+		message,
+	)
+
+	return smtp.SendMail(fmt.Sprintf("%s:%d", s.config.Host, port), auth, s.config.From, s.config.To, []byte(body))
+}
@@ -0,0 +1,16 @@
+package notifier
+
+import "github.com/mudrex/onyx/pkg/logger"
+
+// stdoutNotifier prints the message, used when no sink is configured
+type stdoutNotifier struct{}
+
+func (stdoutNotifier) Send(severity Severity, message string) error {
+	if severity == SeverityCritical {
+		logger.Warn("%s", message)
+		return nil
+	}
+
+	logger.Info("%s", message)
+	return nil
+}
@@ -0,0 +1,29 @@
+package notifier
+
+type teamsRequestBody struct {
+	Type       string `json:"@type"`
+	Context    string `json:"@context"`
+	ThemeColor string `json:"themeColor"`
+	Summary    string `json:"summary"`
+	Text       string `json:"text"`
+}
+
+// teamsNotifier posts a message card to a microsoft teams incoming webhook
+type teamsNotifier struct {
+	hook string
+}
+
+func (t *teamsNotifier) Send(severity Severity, message string) error {
+	themeColor := "0076D7"
+	if severity == SeverityCritical {
+		themeColor = "D70000"
+	}
+
+	return postJSON(t.hook, teamsRequestBody{
+		Type:       "MessageCard",
+		Context:    "http://schema.org/extensions",
+		ThemeColor: themeColor,
+		Summary:    "onyx",
+		Text:       message,
+	})
+}
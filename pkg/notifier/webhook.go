@@ -0,0 +1,31 @@
+package notifier
+
+import (
+	"time"
+
+	"github.com/mudrex/onyx/pkg/config"
+	"github.com/mudrex/onyx/pkg/utils"
+)
+
+type webhookRequestBody struct {
+	Time        string   `json:"time"`
+	Severity    Severity `json:"severity"`
+	Environment string   `json:"environment"`
+	User        string   `json:"user"`
+	Message     string   `json:"message"`
+}
+
+// webhookNotifier posts a generic JSON event to any url
+type webhookNotifier struct {
+	url string
+}
+
+func (w *webhookNotifier) Send(severity Severity, message string) error {
+	return postJSON(w.url, webhookRequestBody{
+		Time:        time.Now().Format(time.RFC3339),
+		Severity:    severity,
+		Environment: config.Config.Environment,
+		User:        utils.GetUser(),
+		Message:     message,
+	})
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 
 	"github.com/mudrex/onyx/pkg/logger"
@@ -23,25 +24,12 @@ func getKeyFile(privateKey string) (key ssh.Signer, err error) {
 }
 
 func CheckIfUserAbleToLogin(privateKey, host, user string) bool {
-	key, err := getKeyFile(privateKey)
-	if err != nil {
-		logger.Error("Unable to parse private key %s. Error: %s", privateKey, err.Error())
-		return false
-	}
-
-	c := &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(key),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-	}
-
-	client, err := ssh.Dial("tcp", host+":22", c)
+	client, err := DialSSH(privateKey, host, user)
 	if err != nil {
 		logger.Error("Unable to connect to host %s@%s. Please check username or private key, Error: %s", user, host, err.Error())
 		return false
 	}
+	defer client.Close()
 
 	session, err := client.NewSession()
 	if err != nil {
@@ -59,3 +47,21 @@ func CheckIfUserAbleToLogin(privateKey, host, user string) bool {
 
 	return true
 }
+
+// DialSSH connects to port 22 of the host as user, authenticating with the private key
+func DialSSH(privateKey, host, user string) (*ssh.Client, error) {
+	key, err := getKeyFile(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key %s: %s", privateKey, err.Error())
+	}
+
+	c := &ssh.ClientConfig{
+		User: user,
+		Auth: []ssh.AuthMethod{
+			ssh.PublicKeys(key),
+		},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+
+	return ssh.Dial("tcp", host+":22", c)
+}
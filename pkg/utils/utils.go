@@ -79,6 +79,11 @@ func GetChunks(arr []string, chunkSize int) [][]string {
 	return chunks
 }
 
+// ShellQuote quotes s as a single argument for sh
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func GetUserInput(message string) string {
 	consoleReader := bufio.NewReader(os.Stdin)
 	fmt.Print(message)